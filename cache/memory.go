@@ -1,11 +1,14 @@
 package cache
 
+import "sync"
+
 type memoryCache struct{
 
 }
 type memoryStore struct {
 	data map[string][]byte
 	region string
+	sync.RWMutex
 }
 
 func MemoryCache() Cache {
@@ -14,17 +17,21 @@ func MemoryCache() Cache {
 }
 func (c *memoryCache) Store(region string) Store {
 	return &memoryStore{
-		make(map[string][]byte),
-		region,
+		data:   make(map[string][]byte),
+		region: region,
 	}
 }
 
 func (s *memoryStore) Get(id string) ([]byte, bool) {
+	s.RLock()
+	defer s.RUnlock()
 	b, ok:=s.data[id]
 	return b, ok
 }
 
 func (s *memoryStore) Set(id string, b []byte) error {
+	s.Lock()
+	defer s.Unlock()
 	s.data[id] = b
 	return nil
 }
\ No newline at end of file
@@ -26,10 +26,14 @@ func (c *redisCache) Store(region string) Store {
 	}
 }
 
+func (s *redisStore) key(id string) string {
+	return s.cache.protocol + "://" + s.region + "/" + id
+}
+
 func (s *redisStore) Get(id string) ([]byte, bool) {
 	r := s.cache.redis.Get()
 	defer r.Close()
-	re, err := r.Do("get", s.cache.protocol+"://"+s.region+"/"+id)
+	re, err := r.Do("get", s.key(id))
 	if err != nil {
 		return nil, false
 	}
@@ -42,5 +46,44 @@ func (s *redisStore) Get(id string) ([]byte, bool) {
 func (s *redisStore) Set(id string, b []byte) error {
 	r := s.cache.redis.Get()
 	defer r.Close()
-	return r.Send("set", s.cache.protocol+"://"+s.region+"/"+id, b)
+	return r.Send("set", s.key(id), b)
+}
+
+// GetMulti reads all ids with a single mget, missing ids are left out of the result.
+func (s *redisStore) GetMulti(ids []string) (map[string][]byte, error) {
+	res := make(map[string][]byte, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+	r := s.cache.redis.Get()
+	defer r.Close()
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = s.key(id)
+	}
+	values, err := redis.ByteSlices(r.Do("mget", args...))
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if v != nil {
+			res[ids[i]] = v
+		}
+	}
+	return res, nil
+}
+
+// SetMulti writes all items with a single mset.
+func (s *redisStore) SetMulti(items map[string][]byte) error {
+	if len(items) == 0 {
+		return nil
+	}
+	r := s.cache.redis.Get()
+	defer r.Close()
+	args := make([]interface{}, 0, len(items)*2)
+	for id, b := range items {
+		args = append(args, s.key(id), b)
+	}
+	_, err := r.Do("mset", args...)
+	return err
 }
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/ueffort/goutils/hash"
+)
+
+// ShardedCache spreads keys over several named redis pools with a consistent hash.
+type ShardedCache struct {
+	shards   map[string]*redisCache
+	ring     *hash.Consistent
+	protocol string
+	sync.RWMutex
+}
+type shardedStore struct {
+	cache  *ShardedCache
+	region string
+}
+
+func ShardedRedisCache(pools map[string]*redis.Pool, protocol string) *ShardedCache {
+	cache := &ShardedCache{
+		shards:   make(map[string]*redisCache),
+		ring:     hash.NewConsistent(),
+		protocol: protocol,
+	}
+	cache.ring.NumberOfReplicas = 160
+	cache.ring.SetHashFunc(hash.Murmur3)
+	for name, pool := range pools {
+		cache.AddShard(name, pool)
+	}
+	return cache
+}
+
+// AddShard adds a pool at runtime, about 1/n of the keys move to it.
+// Adding an existing name replaces its pool.
+func (c *ShardedCache) AddShard(name string, pool *redis.Pool) {
+	c.Lock()
+	defer c.Unlock()
+	c.shards[name] = &redisCache{pool, c.protocol}
+	c.ring.Add(name)
+}
+
+// RemoveShard removes a pool at runtime, its keys move to the remaining pools.
+// The pool itself is not closed.
+func (c *ShardedCache) RemoveShard(name string) {
+	c.Lock()
+	defer c.Unlock()
+	c.ring.Remove(name)
+	delete(c.shards, name)
+}
+
+// Shards returns the names of the pools.
+func (c *ShardedCache) Shards() []string {
+	return c.ring.Members()
+}
+
+// Ring returns the consistent hash routing the keys,
+// e.g. to mark shards down or to plan migrations.
+func (c *ShardedCache) Ring() *hash.Consistent {
+	return c.ring
+}
+
+func (c *ShardedCache) Store(region string) Store {
+	return &shardedStore{
+		c,
+		region,
+	}
+}
+
+// shard returns the store of the pool owning id, the ring and the pools are read
+// under the same lock so a shard removed meanwhile is not returned.
+func (s *shardedStore) shard(id string) (*redisStore, string, error) {
+	s.cache.RLock()
+	defer s.cache.RUnlock()
+	name, err := s.cache.ring.Get(s.cache.protocol + "://" + s.region + "/" + id)
+	if err != nil {
+		return nil, "", err
+	}
+	shard, ok := s.cache.shards[name]
+	if !ok {
+		return nil, "", hash.ErrEmptyCircle
+	}
+	return &redisStore{shard, s.region}, name, nil
+}
+
+func (s *shardedStore) Get(id string) ([]byte, bool) {
+	store, _, err := s.shard(id)
+	if err != nil {
+		return nil, false
+	}
+	return store.Get(id)
+}
+
+func (s *shardedStore) Set(id string, b []byte) error {
+	store, _, err := s.shard(id)
+	if err != nil {
+		return err
+	}
+	return store.Set(id, b)
+}
+
+// GetMulti splits ids per shard, reads each shard with one mget and merges the results.
+func (s *shardedStore) GetMulti(ids []string) (map[string][]byte, error) {
+	stores := make(map[string]*redisStore)
+	groups := make(map[string][]string)
+	for _, id := range ids {
+		store, name, err := s.shard(id)
+		if err != nil {
+			return nil, err
+		}
+		stores[name] = store
+		groups[name] = append(groups[name], id)
+	}
+	res := make(map[string][]byte, len(ids))
+	for name, group := range groups {
+		values, err := stores[name].GetMulti(group)
+		if err != nil {
+			return nil, err
+		}
+		for id, v := range values {
+			res[id] = v
+		}
+	}
+	return res, nil
+}
+
+// SetMulti splits items per shard and writes each shard with one mset.
+func (s *shardedStore) SetMulti(items map[string][]byte) error {
+	stores := make(map[string]*redisStore)
+	groups := make(map[string]map[string][]byte)
+	for id, b := range items {
+		store, name, err := s.shard(id)
+		if err != nil {
+			return err
+		}
+		if groups[name] == nil {
+			stores[name] = store
+			groups[name] = make(map[string][]byte)
+		}
+		groups[name][id] = b
+	}
+	for name, group := range groups {
+		if err := stores[name].SetMulti(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/garyburd/redigo/redis"
+)
+
+func newShards(t *testing.T, names ...string) (map[string]*miniredis.Miniredis, map[string]*redis.Pool) {
+	servers := make(map[string]*miniredis.Miniredis)
+	pools := make(map[string]*redis.Pool)
+	for _, name := range names {
+		s, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Close)
+		addr := s.Addr()
+		servers[name] = s
+		pools[name] = &redis.Pool{Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		}}
+	}
+	return servers, pools
+}
+
+func TestShardedRouting(t *testing.T) {
+	servers, pools := newShards(t, "a", "b", "c")
+	cache := ShardedRedisCache(pools, "test")
+	store := cache.Store("users")
+
+	for i := 0; i < 30; i++ {
+		id := strconv.Itoa(i)
+		if err := store.Set(id, []byte("v"+id)); err != nil {
+			t.Fatal(err)
+		}
+		owner, _ := cache.Ring().Get("test://users/" + id)
+		for name, s := range servers {
+			if s.Exists("test://users/"+id) != (name == owner) {
+				t.Errorf("key %s on %s, owned by %s", id, name, owner)
+			}
+		}
+		if v, ok := store.Get(id); !ok || string(v) != "v"+id {
+			t.Errorf("Get(%s) = %q, %t", id, v, ok)
+		}
+	}
+}
+
+func TestShardedMulti(t *testing.T) {
+	servers, pools := newShards(t, "a", "b", "c")
+	store := ShardedRedisCache(pools, "test").Store("users")
+
+	items := make(map[string][]byte)
+	ids := []string{"missing"}
+	for i := 0; i < 50; i++ {
+		id := strconv.Itoa(i)
+		items[id] = []byte("v" + id)
+		ids = append(ids, id)
+	}
+	if err := store.(MultiStore).SetMulti(items); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for name, s := range servers {
+		if n := len(s.Keys()); n == 0 {
+			t.Errorf("shard %s got no keys", name)
+		} else {
+			total += n
+		}
+	}
+	if total != len(items) {
+		t.Errorf("%d keys stored for %d items", total, len(items))
+	}
+	res, err := store.(MultiStore).GetMulti(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(items) {
+		t.Errorf("GetMulti returned %d values", len(res))
+	}
+	for id, v := range items {
+		if string(res[id]) != string(v) {
+			t.Errorf("GetMulti[%s] = %q", id, res[id])
+		}
+	}
+}
+
+func TestShardedAddRemove(t *testing.T) {
+	servers, pools := newShards(t, "a", "b", "c")
+	cache := ShardedRedisCache(map[string]*redis.Pool{"a": pools["a"], "b": pools["b"]}, "test")
+	store := cache.Store("users")
+
+	cache.AddShard("c", pools["c"])
+	if shards := cache.Shards(); len(shards) != 3 {
+		t.Errorf("unexpected shards %v", shards)
+	}
+	for i := 0; i < 30; i++ {
+		store.Set(strconv.Itoa(i), []byte("v"))
+	}
+	if len(servers["c"].Keys()) == 0 {
+		t.Error("the added shard got no keys")
+	}
+
+	cache.RemoveShard("c")
+	if shards := cache.Shards(); len(shards) != 2 {
+		t.Errorf("unexpected shards %v", shards)
+	}
+	servers["c"].FlushAll()
+	for i := 0; i < 30; i++ {
+		store.Set(strconv.Itoa(i), []byte("v"))
+	}
+	if keys := servers["c"].Keys(); len(keys) != 0 {
+		t.Errorf("the removed shard got %v", keys)
+	}
+
+	// the keys are always routed while a shard comes and goes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			cache.AddShard("c", pools["c"])
+			cache.RemoveShard("c")
+		}
+	}()
+	for i := 0; ; i++ {
+		if err := store.Set(strconv.Itoa(i), []byte("v")); err != nil {
+			t.Fatalf("Set during a resharding: %v", err)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
@@ -5,6 +5,13 @@ type Store interface {
 	Set(key string, b []byte) error
 }
 
+// MultiStore is a Store that can read and write several keys in one round trip.
+type MultiStore interface {
+	Store
+	GetMulti(keys []string) (map[string][]byte, error)
+	SetMulti(items map[string][]byte) error
+}
+
 type Cache interface {
 	Store(region string) Store
 }
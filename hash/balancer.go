@@ -0,0 +1,51 @@
+package hash
+
+import "hash/fnv"
+
+// Balancer places names on a changing set of members.
+//
+// Implementations trade memory, lookup speed and remapping differently:
+//
+//	Consistent  ring of virtual nodes, memory grows with replicas × members
+//	Jump        no memory, but members can only be removed cheaply from the end
+//	Rendezvous  no memory, lookups cost O(members)
+//	Maglev      fixed size lookup table, O(1) lookups, rebuilt on every change
+type Balancer interface {
+	Get(name string) (string, error)
+	GetN(name string, n int) ([]string, error)
+	Add(elt string)
+	Remove(elt string)
+}
+
+var (
+	_ Balancer = (*Consistent)(nil)
+	_ Balancer = (*Jump)(nil)
+	_ Balancer = (*Rendezvous)(nil)
+	_ Balancer = (*Maglev)(nil)
+)
+
+// hash64 is the 64-bit FNV-1a hash of s.
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer, it spreads the low entropy of short keys over all bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func indexOf(set []string, member string) int {
+	for i, m := range set {
+		if m == member {
+			return i
+		}
+	}
+	return -1
+}
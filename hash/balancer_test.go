@@ -0,0 +1,182 @@
+package hash
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func newBalancers() map[string]func() Balancer {
+	return map[string]func() Balancer{
+		"consistent": func() Balancer {
+			c := NewConsistent()
+			c.NumberOfReplicas = 200
+			c.SetHashFunc(Murmur3)
+			return c
+		},
+		"jump":       func() Balancer { return NewJump() },
+		"rendezvous": func() Balancer { return NewRendezvous() },
+		"maglev":     func() Balancer { return NewMaglev(0) },
+	}
+}
+
+func lookupAll(b Balancer, keys int) []string {
+	res := make([]string, keys)
+	for i := range res {
+		m, err := b.Get("key" + strconv.Itoa(i))
+		if err != nil {
+			panic(err)
+		}
+		res[i] = m
+	}
+	return res
+}
+
+func TestBalancerBalance(t *testing.T) {
+	const (
+		members   = 10
+		keys      = 100000
+		tolerance = 0.2
+	)
+	for name, fn := range newBalancers() {
+		b := fn()
+		for i := 0; i < members; i++ {
+			b.Add("server" + strconv.Itoa(i))
+		}
+		dist := make(map[string]int)
+		for _, m := range lookupAll(b, keys) {
+			dist[m]++
+		}
+		if len(dist) != members {
+			t.Errorf("%s: keys landed on %d members, want %d", name, len(dist), members)
+		}
+		mean := float64(keys) / members
+		for m, n := range dist {
+			if dev := math.Abs(float64(n)-mean) / mean; dev > tolerance {
+				t.Errorf("%s: %s got %d keys, %.0f%% off the mean", name, m, n, dev*100)
+			}
+		}
+	}
+}
+
+func TestBalancerRemap(t *testing.T) {
+	const (
+		members = 10
+		keys    = 100000
+	)
+	// adding the 11th member ideally moves 1/11 of the keys
+	ideal := 1.0 / (members + 1)
+	for name, fn := range newBalancers() {
+		b := fn()
+		for i := 0; i < members; i++ {
+			b.Add("server" + strconv.Itoa(i))
+		}
+		before := lookupAll(b, keys)
+		b.Add("server" + strconv.Itoa(members))
+		after := lookupAll(b, keys)
+
+		moved := 0
+		for i := range before {
+			if before[i] != after[i] {
+				moved++
+				if after[i] != "server"+strconv.Itoa(members) {
+					// only maglev may shuffle keys between old members
+					if name != "maglev" {
+						t.Fatalf("%s: key%d moved from %s to %s", name, i, before[i], after[i])
+					}
+				}
+			}
+		}
+		ratio := float64(moved) / keys
+		t.Logf("%s: remapped %.2f%% of keys, ideal %.2f%%", name, ratio*100, ideal*100)
+		if ratio > ideal*1.5 {
+			t.Errorf("%s: remapped %.2f%% of keys, want at most %.2f%%", name, ratio*100, ideal*150)
+		}
+	}
+}
+
+func TestBalancerGetN(t *testing.T) {
+	for name, fn := range newBalancers() {
+		b := fn()
+		if _, err := b.Get("x"); err != ErrEmptyCircle {
+			t.Errorf("%s: empty Get returned %v", name, err)
+		}
+		for i := 0; i < 5; i++ {
+			b.Add("server" + strconv.Itoa(i))
+		}
+		res, err := b.GetN("some key", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, _ := b.Get("some key")
+		if len(res) != 3 || res[0] != first {
+			t.Errorf("%s: GetN = %v, first should be %s", name, res, first)
+		}
+		seen := make(map[string]bool)
+		for _, m := range res {
+			if seen[m] {
+				t.Errorf("%s: GetN returned duplicate %s", name, m)
+			}
+			seen[m] = true
+		}
+		if res, _ = b.GetN("some key", 10); len(res) != 5 {
+			t.Errorf("%s: GetN(10) returned %d members, want 5", name, len(res))
+		}
+		b.Remove("server0")
+		if res, _ = b.GetN("some key", 10); len(res) != 4 || sliceContainsMember(res, "server0") {
+			t.Errorf("%s: GetN after remove = %v", name, res)
+		}
+	}
+}
+
+func TestBalancerGetNNonPositive(t *testing.T) {
+	for name, fn := range newBalancers() {
+		b := fn()
+		b.Add("server0")
+		b.Add("server1")
+		for _, n := range []int{-1, 0} {
+			if res, err := b.GetN("some key", n); err != nil || res == nil || len(res) != 0 {
+				t.Errorf("%s: GetN(%d) = %v, %v", name, n, res, err)
+			}
+		}
+	}
+}
+
+func TestMaglevSize(t *testing.T) {
+	for size, prime := range map[int]int{1: 2, 2: 2, 1000: 1009, 65537: 65537} {
+		m := NewMaglev(size)
+		if m.size != prime {
+			t.Errorf("NewMaglev(%d) size = %d", size, m.size)
+		}
+		for i := 0; i < 10; i++ {
+			m.Add("server" + strconv.Itoa(i))
+		}
+		if _, err := m.Get("key"); err != nil {
+			t.Errorf("size %d: %v", size, err)
+		}
+	}
+}
+
+func benchmarkBalancer(b *testing.B, name string, members int) {
+	bl := newBalancers()[name]()
+	for i := 0; i < members; i++ {
+		bl.Add("server" + strconv.Itoa(i))
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.Get(keys[i&1023])
+	}
+}
+
+func BenchmarkConsistentGet10(b *testing.B)  { benchmarkBalancer(b, "consistent", 10) }
+func BenchmarkJumpGet10(b *testing.B)        { benchmarkBalancer(b, "jump", 10) }
+func BenchmarkRendezvousGet10(b *testing.B)  { benchmarkBalancer(b, "rendezvous", 10) }
+func BenchmarkMaglevGet10(b *testing.B)      { benchmarkBalancer(b, "maglev", 10) }
+func BenchmarkConsistentGet100(b *testing.B) { benchmarkBalancer(b, "consistent", 100) }
+func BenchmarkJumpGet100(b *testing.B)       { benchmarkBalancer(b, "jump", 100) }
+func BenchmarkRendezvousGet100(b *testing.B) { benchmarkBalancer(b, "rendezvous", 100) }
+func BenchmarkMaglevGet100(b *testing.B)     { benchmarkBalancer(b, "maglev", 100) }
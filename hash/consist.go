@@ -47,7 +47,10 @@ var ErrEmptyCircle = errors.New("empty circle")
 type Consistent struct {
 	circle           map[uint32]string
 	members          map[string]bool
+	weights          map[string]int
+	down             map[string]bool
 	sortedHashes     uints
+	hash             HashFunc
 	NumberOfReplicas int
 	count            int64
 	scratch          [64]byte
@@ -62,9 +65,25 @@ func NewConsistent() *Consistent {
 	c.NumberOfReplicas = 20
 	c.circle = make(map[uint32]string)
 	c.members = make(map[string]bool)
+	c.weights = make(map[string]int)
+	c.down = make(map[string]bool)
 	return c
 }
 
+// SetHashFunc replaces the function used to place elements and names on the circle.
+// Existing elements are re-hashed, so it is cheaper to call it before adding entries.
+// A nil fn restores the default CRC32 hashing.
+func (c *Consistent) SetHashFunc(fn HashFunc) {
+	c.Lock()
+	defer c.Unlock()
+	c.hash = fn
+	c.circle = make(map[uint32]string)
+	for elt := range c.members {
+		c.place(elt, c.weights[elt])
+	}
+	c.updateSortedHashes()
+}
+
 // eltKey generates a string key for an element with an index.
 func (c *Consistent) eltKey(elt string, idx int) string {
 	// return elt + "|" + strconv.Itoa(idx)
@@ -75,19 +94,51 @@ func (c *Consistent) eltKey(elt string, idx int) string {
 func (c *Consistent) Add(elt string) {
 	c.Lock()
 	defer c.Unlock()
-	c.add(elt)
+	c.add(elt, 1)
 }
 
-// need c.Lock() before calling
-func (c *Consistent) add(elt string) {
-	for i := 0; i < c.NumberOfReplicas; i++ {
-		c.circle[c.hashKey(c.eltKey(elt, i))] = elt
+// AddWeighted inserts a string element with NumberOfReplicas*weight virtual nodes,
+// so it receives a share of the keys proportional to weight.
+// Adding an existing element changes its weight and keeps it marked down.
+func (c *Consistent) AddWeighted(elt string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	c.Lock()
+	defer c.Unlock()
+	down := c.down[elt]
+	if _, ok := c.members[elt]; ok {
+		c.remove(elt)
 	}
+	c.add(elt, weight)
+	if down {
+		c.down[elt] = true
+	}
+}
+
+// Weight returns the weight of elt, 0 if it is not a member.
+func (c *Consistent) Weight(elt string) int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.weights[elt]
+}
+
+// need c.Lock() before calling
+func (c *Consistent) add(elt string, weight int) {
+	c.place(elt, weight)
 	c.members[elt] = true
+	c.weights[elt] = weight
 	c.updateSortedHashes()
 	c.count++
 }
 
+// need c.Lock() before calling
+func (c *Consistent) place(elt string, weight int) {
+	for i := 0; i < c.NumberOfReplicas*weight; i++ {
+		c.circle[c.hashKey(c.eltKey(elt, i))] = elt
+	}
+}
+
 // Remove removes an element from the hash.
 func (c *Consistent) Remove(elt string) {
 	c.Lock()
@@ -97,10 +148,15 @@ func (c *Consistent) Remove(elt string) {
 
 // need c.Lock() before calling
 func (c *Consistent) remove(elt string) {
-	for i := 0; i < c.NumberOfReplicas; i++ {
+	if !c.members[elt] {
+		return
+	}
+	for i := 0; i < c.NumberOfReplicas*c.weights[elt]; i++ {
 		delete(c.circle, c.hashKey(c.eltKey(elt, i)))
 	}
 	delete(c.members, elt)
+	delete(c.weights, elt)
+	delete(c.down, elt)
 	c.updateSortedHashes()
 	c.count--
 }
@@ -127,7 +183,7 @@ func (c *Consistent) Set(elts []string) {
 		if exists {
 			continue
 		}
-		c.add(v)
+		c.add(v, 1)
 	}
 }
 
@@ -142,6 +198,7 @@ func (c *Consistent) Members() []string {
 }
 
 // Get returns an element close to where name hashes to in the circle.
+// Elements marked down are skipped, their keys go to the next healthy element.
 func (c *Consistent) Get(name string) (string, error) {
 	c.RLock()
 	defer c.RUnlock()
@@ -150,7 +207,22 @@ func (c *Consistent) Get(name string) (string, error) {
 	}
 	key := c.hashKey(name)
 	i := c.search(key)
-	return c.circle[c.sortedHashes[i]], nil
+	elem := c.circle[c.sortedHashes[i]]
+	if !c.down[elem] {
+		return elem, nil
+	}
+	for start := i; ; {
+		if i++; i >= len(c.sortedHashes) {
+			i = 0
+		}
+		if i == start {
+			return "", ErrNoHealthyMember
+		}
+		elem = c.circle[c.sortedHashes[i]]
+		if !c.down[elem] {
+			return elem, nil
+		}
+	}
 }
 
 func (c *Consistent) search(key uint32) (i int) {
@@ -166,34 +238,18 @@ func (c *Consistent) search(key uint32) (i int) {
 
 // GetTwo returns the two closest distinct elements to the name input in the circle.
 func (c *Consistent) GetTwo(name string) (string, string, error) {
-	c.RLock()
-	defer c.RUnlock()
-	if len(c.circle) == 0 {
-		return "", "", ErrEmptyCircle
+	res, err := c.GetN(name, 2)
+	if err != nil {
+		return "", "", err
 	}
-	key := c.hashKey(name)
-	i := c.search(key)
-	a := c.circle[c.sortedHashes[i]]
-
-	if c.count == 1 {
-		return a, "", nil
-	}
-
-	start := i
-	var b string
-	for i = start + 1; i != start; i++ {
-		if i >= len(c.sortedHashes) {
-			i = 0
-		}
-		b = c.circle[c.sortedHashes[i]]
-		if b != a {
-			break
-		}
+	if len(res) == 1 {
+		return res[0], "", nil
 	}
-	return a, b, nil
+	return res[0], res[1], nil
 }
 
 // GetN returns the N closest distinct elements to the name input in the circle.
+// Elements marked down are skipped.
 func (c *Consistent) GetN(name string, n int) ([]string, error) {
 	c.RLock()
 	defer c.RUnlock()
@@ -202,8 +258,15 @@ func (c *Consistent) GetN(name string, n int) ([]string, error) {
 		return nil, ErrEmptyCircle
 	}
 
-	if c.count < int64(n) {
-		n = int(c.count)
+	healthy := len(c.members) - len(c.down)
+	if healthy <= 0 {
+		return nil, ErrNoHealthyMember
+	}
+	if healthy < n {
+		n = healthy
+	}
+	if n <= 0 {
+		return []string{}, nil
 	}
 
 	var (
@@ -211,32 +274,32 @@ func (c *Consistent) GetN(name string, n int) ([]string, error) {
 		i     = c.search(key)
 		start = i
 		res   = make([]string, 0, n)
-		elem  = c.circle[c.sortedHashes[i]]
+		elem  string
 	)
 
-	res = append(res, elem)
-
-	if len(res) == n {
-		return res, nil
-	}
-
-	for i = start + 1; i != start; i++ {
-		if i >= len(c.sortedHashes) {
-			i = 0
-		}
+	for {
 		elem = c.circle[c.sortedHashes[i]]
-		if !sliceContainsMember(res, elem) {
+		if !c.down[elem] && !sliceContainsMember(res, elem) {
 			res = append(res, elem)
 		}
 		if len(res) == n {
 			break
 		}
+		if i++; i >= len(c.sortedHashes) {
+			i = 0
+		}
+		if i == start {
+			break
+		}
 	}
 
 	return res, nil
 }
 
 func (c *Consistent) hashKey(key string) uint32 {
+	if c.hash != nil {
+		return c.hash([]byte(key))
+	}
 	if len(key) < 64 {
 		var scratch [64]byte
 		copy(scratch[:], key)
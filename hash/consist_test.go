@@ -0,0 +1,103 @@
+package hash
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHashFuncVectors(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   HashFunc
+		in   string
+		out  uint32
+	}{
+		{"xxhash", XXHash, "", 0x02cc5d05},
+		{"xxhash", XXHash, "abc", 0x32d153ff},
+		{"xxhash", XXHash, "Nobody inspects the spammish repetition", 0xe2293b2f},
+		{"murmur3", Murmur3, "", 0},
+		{"murmur3", Murmur3, "hello", 0x248bfa47},
+		{"murmur3", Murmur3, "The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+		{"fnv1a", FNV1a, "a", 0xe40c292c},
+		{"crc32", CRC32, "hello", 0x3610a686},
+	}
+	for _, c := range cases {
+		if h := c.fn([]byte(c.in)); h != c.out {
+			t.Errorf("%s(%q) = %#x, want %#x", c.name, c.in, h, c.out)
+		}
+	}
+}
+
+// distribution hashes n keys and returns the share of each member.
+func distribution(c *Consistent, n int) map[string]int {
+	res := make(map[string]int)
+	for i := 0; i < n; i++ {
+		m, err := c.Get("key" + strconv.Itoa(i))
+		if err != nil {
+			panic(err)
+		}
+		res[m]++
+	}
+	return res
+}
+
+func TestConsistentBalance(t *testing.T) {
+	const (
+		members   = 10
+		keys      = 100000
+		tolerance = 0.2
+	)
+	// CRC32 is left out: it stays the default for compatibility with existing
+	// rings, but clusters the short "idx+elt" virtual node keys too much.
+	funcs := map[string]HashFunc{
+		"fnv1a":   FNV1a,
+		"murmur3": Murmur3,
+		"xxhash":  XXHash,
+	}
+	for name, fn := range funcs {
+		c := NewConsistent()
+		c.NumberOfReplicas = 200
+		c.SetHashFunc(fn)
+		for i := 0; i < members; i++ {
+			c.Add("server" + strconv.Itoa(i))
+		}
+		mean := float64(keys) / members
+		for m, n := range distribution(c, keys) {
+			if dev := math.Abs(float64(n)-mean) / mean; dev > tolerance {
+				t.Errorf("%s: %s got %d keys, %.0f%% off the mean", name, m, n, dev*100)
+			}
+		}
+	}
+}
+
+func TestConsistentWeighted(t *testing.T) {
+	const (
+		keys      = 100000
+		tolerance = 0.2
+	)
+	c := NewConsistent()
+	c.NumberOfReplicas = 100
+	c.SetHashFunc(Murmur3)
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	total := 0
+	for m, w := range weights {
+		c.AddWeighted(m, w)
+		total += w
+	}
+	dist := distribution(c, keys)
+	for m, w := range weights {
+		want := float64(keys) * float64(w) / float64(total)
+		if dev := math.Abs(float64(dist[m])-want) / want; dev > tolerance {
+			t.Errorf("%s (weight %d) got %d keys, want about %.0f", m, w, dist[m], want)
+		}
+	}
+
+	c.Remove("large")
+	if len(c.circle) != 3*c.NumberOfReplicas {
+		t.Errorf("circle has %d points after remove, want %d", len(c.circle), 3*c.NumberOfReplicas)
+	}
+	if c.Weight("large") != 0 || c.Weight("medium") != 2 {
+		t.Errorf("unexpected weights after remove: %v", c.weights)
+	}
+}
@@ -0,0 +1,86 @@
+package hash
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoHealthyMember is the error returned when every element of the hash is marked down.
+var ErrNoHealthyMember = errors.New("no healthy member")
+
+// HealthChecker reports whether a member is able to serve requests.
+type HealthChecker func(member string) bool
+
+// MarkDown marks a member as unhealthy. Its keys are served by the next healthy
+// member on the circle, but it keeps its virtual nodes so nothing is reshuffled on recovery.
+func (c *Consistent) MarkDown(elt string) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.members[elt]; ok {
+		c.down[elt] = true
+	}
+}
+
+// MarkUp marks a member as healthy again.
+func (c *Consistent) MarkUp(elt string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.down, elt)
+}
+
+// IsUp reports whether elt is a member that is not marked down.
+func (c *Consistent) IsUp(elt string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.members[elt] && !c.down[elt]
+}
+
+// Down returns the members marked down.
+func (c *Consistent) Down() []string {
+	c.RLock()
+	defer c.RUnlock()
+	var m []string
+	for k := range c.down {
+		m = append(m, k)
+	}
+	return m
+}
+
+// Check runs checker against every member once and marks them up or down.
+// onChange, if not nil, is called for each member whose state changed.
+func (c *Consistent) Check(checker HealthChecker, onChange func(member string, up bool)) {
+	for _, m := range c.Members() {
+		up := checker(m)
+		if up == c.IsUp(m) {
+			continue
+		}
+		if up {
+			c.MarkUp(m)
+		} else {
+			c.MarkDown(m)
+		}
+		if onChange != nil {
+			onChange(m, up)
+		}
+	}
+}
+
+// Watch runs Check every interval in the background until the returned stop function is called.
+func (c *Consistent) Watch(checker HealthChecker, interval time.Duration, onChange func(member string, up bool)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Check(checker, onChange)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
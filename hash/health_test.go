@@ -0,0 +1,101 @@
+package hash
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConsistentMarkDown(t *testing.T) {
+	c := NewConsistent()
+	c.Set([]string{"a", "b", "c"})
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		owners[key], _ = c.Get(key)
+	}
+
+	c.MarkDown("b")
+	for key, owner := range owners {
+		got, err := c.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == "b" || (owner != "b" && got != owner) {
+			t.Fatalf("%s: got %s, owner %s", key, got, owner)
+		}
+		res, _ := c.GetN(key, 3)
+		if len(res) != 2 || sliceContainsMember(res, "b") {
+			t.Fatalf("%s: GetN returned %v", key, res)
+		}
+	}
+
+	c.MarkUp("b")
+	for key, owner := range owners {
+		if got, _ := c.Get(key); got != owner {
+			t.Fatalf("%s: got %s after recovery, want %s", key, got, owner)
+		}
+	}
+
+	c.MarkDown("a")
+	c.MarkDown("b")
+	c.MarkDown("c")
+	if _, err := c.Get("x"); err != ErrNoHealthyMember {
+		t.Errorf("Get with all members down returned %v", err)
+	}
+	if _, _, err := c.GetTwo("x"); err != ErrNoHealthyMember {
+		t.Errorf("GetTwo with all members down returned %v", err)
+	}
+
+	// a re-weighted member stays down
+	c.AddWeighted("a", 2)
+	if c.IsUp("a") || c.Weight("a") != 2 {
+		t.Errorf("a is up %t with weight %d", c.IsUp("a"), c.Weight("a"))
+	}
+	c.Remove("a")
+	c.Add("a")
+	if !c.IsUp("a") {
+		t.Error("a member added again is down")
+	}
+}
+
+func TestConsistentRemoveUnknown(t *testing.T) {
+	c := NewConsistent()
+	c.Add("a")
+	c.Remove("zzz")
+	if got, err := c.Get("x"); err != nil || got != "a" {
+		t.Errorf("Get returned %q, %v", got, err)
+	}
+	if first, _, err := c.GetTwo("x"); err != nil || first != "a" {
+		t.Errorf("GetTwo returned %q, %v", first, err)
+	}
+	if res, err := c.GetN("x", 3); err != nil || len(res) != 1 {
+		t.Errorf("GetN returned %v, %v", res, err)
+	}
+}
+
+func TestConsistentWatch(t *testing.T) {
+	c := NewConsistent()
+	c.Set([]string{"a", "b"})
+	var healthy atomic.Value
+	healthy.Store(false)
+	changes := make(chan string, 4)
+	stop := c.Watch(func(m string) bool {
+		return m == "a" || healthy.Load().(bool)
+	}, time.Millisecond, func(m string, up bool) {
+		changes <- m + strconv.FormatBool(up)
+	})
+	defer stop()
+
+	if ch := <-changes; ch != "bfalse" {
+		t.Fatalf("unexpected change %s", ch)
+	}
+	if c.IsUp("b") || !c.IsUp("a") {
+		t.Fatal("b should be down and a up")
+	}
+	healthy.Store(true)
+	if ch := <-changes; ch != "btrue" {
+		t.Fatalf("unexpected change %s", ch)
+	}
+}
@@ -0,0 +1,92 @@
+package hash
+
+import "sync"
+
+// Jump implements the jump consistent hash of Lamping and Veach.
+//
+// It needs no memory besides the member list, but keys only move minimally when
+// members are added or removed at the end of the list. Removing a member from the
+// middle moves the last member into its slot.
+//
+// Read more: https://arxiv.org/abs/1406.2294
+type Jump struct {
+	members []string
+	sync.RWMutex
+}
+
+// NewJump creates a new Jump object.
+func NewJump() *Jump {
+	return new(Jump)
+}
+
+// Add appends a member, it is ignored if elt is already a member.
+func (j *Jump) Add(elt string) {
+	j.Lock()
+	defer j.Unlock()
+	if indexOf(j.members, elt) >= 0 {
+		return
+	}
+	j.members = append(j.members, elt)
+}
+
+// Remove removes a member, the last member takes over its bucket.
+func (j *Jump) Remove(elt string) {
+	j.Lock()
+	defer j.Unlock()
+	i := indexOf(j.members, elt)
+	if i < 0 {
+		return
+	}
+	last := len(j.members) - 1
+	j.members[i] = j.members[last]
+	j.members = j.members[:last]
+}
+
+// Members returns the members in bucket order.
+func (j *Jump) Members() []string {
+	j.RLock()
+	defer j.RUnlock()
+	return append([]string(nil), j.members...)
+}
+
+// Get returns the member name hashes to.
+func (j *Jump) Get(name string) (string, error) {
+	j.RLock()
+	defer j.RUnlock()
+	if len(j.members) == 0 {
+		return "", ErrEmptyCircle
+	}
+	return j.members[jumpHash(hash64(name), len(j.members))], nil
+}
+
+// GetN returns the N distinct members for name, starting with the one Get returns.
+func (j *Jump) GetN(name string, n int) ([]string, error) {
+	j.RLock()
+	defer j.RUnlock()
+	if len(j.members) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if n > len(j.members) {
+		n = len(j.members)
+	}
+	if n <= 0 {
+		return []string{}, nil
+	}
+	res := make([]string, 0, n)
+	b := jumpHash(hash64(name), len(j.members))
+	for i := 0; i < n; i++ {
+		res = append(res, j.members[(b+i)%len(j.members)])
+	}
+	return res, nil
+}
+
+// jumpHash maps key to a bucket in [0, buckets).
+func jumpHash(key uint64, buckets int) int {
+	var b, k int64 = -1, 0
+	for k < int64(buckets) {
+		b = k
+		key = key*2862933555777941757 + 1
+		k = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
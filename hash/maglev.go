@@ -0,0 +1,159 @@
+package hash
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultMaglevSize is the lookup table size used by NewMaglev when size is 0.
+// It should be a prime much larger than the number of members.
+const DefaultMaglevSize = 65537
+
+// Maglev implements the lookup table of Google's Maglev load balancer.
+//
+// Lookups are a single table access and the load is almost perfectly even, at the
+// cost of rebuilding the table on every membership change and slightly more
+// disruption than a ring.
+//
+// Read more: https://research.google/pubs/pub44824/
+type Maglev struct {
+	members []string
+	table   []int
+	size    int
+	sync.RWMutex
+}
+
+// NewMaglev creates a new Maglev object with a lookup table of size entries,
+// rounded up to a prime so every member permutation fills the table.
+func NewMaglev(size int) *Maglev {
+	if size <= 0 {
+		size = DefaultMaglevSize
+	}
+	return &Maglev{size: nextPrime(size)}
+}
+
+// nextPrime returns the smallest prime not lower than n.
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := 3; d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+// Add inserts a member and rebuilds the table, it is ignored if elt is already a member.
+func (m *Maglev) Add(elt string) {
+	m.Lock()
+	defer m.Unlock()
+	if indexOf(m.members, elt) >= 0 {
+		return
+	}
+	m.members = append(m.members, elt)
+	m.populate()
+}
+
+// Remove removes a member and rebuilds the table.
+func (m *Maglev) Remove(elt string) {
+	m.Lock()
+	defer m.Unlock()
+	i := indexOf(m.members, elt)
+	if i < 0 {
+		return
+	}
+	m.members = append(m.members[:i], m.members[i+1:]...)
+	m.populate()
+}
+
+// Members returns the members.
+func (m *Maglev) Members() []string {
+	m.RLock()
+	defer m.RUnlock()
+	return append([]string(nil), m.members...)
+}
+
+// Get returns the member owning the table entry name hashes to.
+func (m *Maglev) Get(name string) (string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if len(m.members) == 0 {
+		return "", ErrEmptyCircle
+	}
+	return m.members[m.table[hash64(name)%uint64(m.size)]], nil
+}
+
+// GetN returns the N distinct members found walking the table from the entry name hashes to.
+func (m *Maglev) GetN(name string, n int) ([]string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if len(m.members) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if n > len(m.members) {
+		n = len(m.members)
+	}
+	if n <= 0 {
+		return []string{}, nil
+	}
+	res := make([]string, 0, n)
+	start := int(hash64(name) % uint64(m.size))
+	for i := 0; i < m.size && len(res) < n; i++ {
+		elem := m.members[m.table[(start+i)%m.size]]
+		if !sliceContainsMember(res, elem) {
+			res = append(res, elem)
+		}
+	}
+	return res, nil
+}
+
+// need m.Lock() before calling
+func (m *Maglev) populate() {
+	if len(m.members) == 0 {
+		m.table = nil
+		return
+	}
+	// the table must not depend on insertion order
+	sort.Strings(m.members)
+	size := uint64(m.size)
+	n := len(m.members)
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, elt := range m.members {
+		h := hash64(elt)
+		offsets[i] = h % size
+		skips[i] = mix64(h)%(size-1) + 1
+	}
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	for filled := 0; ; {
+		for i := 0; i < n; i++ {
+			c := (offsets[i] + next[i]*skips[i]) % size
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % size
+			}
+			table[c] = i
+			next[i]++
+			filled++
+			if filled == m.size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package hash
+
+import "sort"
+
+// HashRange is the half-open interval [Start, End) of hashes on the circle.
+// If Start >= End the range wraps around zero.
+type HashRange struct {
+	Start uint32
+	End   uint32
+}
+
+// Contains reports whether hash h falls into the range.
+func (r HashRange) Contains(h uint32) bool {
+	if r.Start < r.End {
+		return h >= r.Start && h < r.End
+	}
+	return h >= r.Start || h < r.End
+}
+
+// Migration describes the keys of a hash range changing owner.
+type Migration struct {
+	From  string
+	To    string
+	Range HashRange
+}
+
+// HashOf returns the position name hashes to on the circle,
+// it can be matched against the ranges returned by Diff.
+func (c *Consistent) HashOf(name string) uint32 {
+	c.RLock()
+	defer c.RUnlock()
+	return c.hashKey(name)
+}
+
+// Clone returns an independent copy of the circle.
+func (c *Consistent) Clone() *Consistent {
+	c.RLock()
+	defer c.RUnlock()
+	n := NewConsistent()
+	n.NumberOfReplicas = c.NumberOfReplicas
+	n.hash = c.hash
+	n.count = c.count
+	for k, v := range c.circle {
+		n.circle[k] = v
+	}
+	for k, v := range c.members {
+		n.members[k] = v
+	}
+	for k, v := range c.weights {
+		n.weights[k] = v
+	}
+	for k, v := range c.down {
+		n.down[k] = v
+	}
+	n.sortedHashes = append(uints(nil), c.sortedHashes...)
+	return n
+}
+
+// Plan returns the migrations Set(elts) would cause, without changing the circle.
+func (c *Consistent) Plan(elts []string) []Migration {
+	next := c.Clone()
+	next.Set(elts)
+	return Diff(c, next)
+}
+
+// Diff compares two states of a circle and returns the hash ranges whose owner
+// changed, in hash order. Both circles must use the same hash function.
+// Nothing has to move if either circle is empty.
+func Diff(from, to *Consistent) []Migration {
+	if from == to {
+		return nil
+	}
+	// a snapshot of from, so the two circles are never locked together
+	from = from.Clone()
+	to.RLock()
+	defer to.RUnlock()
+	if len(from.circle) == 0 || len(to.circle) == 0 {
+		return nil
+	}
+
+	bounds := make(uints, 0, len(from.sortedHashes)+len(to.sortedHashes))
+	bounds = append(bounds, from.sortedHashes...)
+	bounds = append(bounds, to.sortedHashes...)
+	sort.Sort(bounds)
+
+	var res []Migration
+	for i, start := range bounds {
+		if i > 0 && start == bounds[i-1] {
+			continue
+		}
+		end := bounds[0]
+		for j := i + 1; j < len(bounds); j++ {
+			if bounds[j] != start {
+				end = bounds[j]
+				break
+			}
+		}
+		// no point of either circle lies inside [start, end), so a single lookup gives the owner
+		a := from.circle[from.sortedHashes[from.search(start)]]
+		b := to.circle[to.sortedHashes[to.search(start)]]
+		if a == b {
+			continue
+		}
+		if l := len(res) - 1; l >= 0 && res[l].From == a && res[l].To == b && res[l].Range.End == start {
+			res[l].Range.End = end
+			continue
+		}
+		res = append(res, Migration{a, b, HashRange{start, end}})
+	}
+	// join the last range with the first one when they meet at zero
+	if l := len(res) - 1; l > 0 && res[l].From == res[0].From && res[l].To == res[0].To && res[l].Range.End == res[0].Range.Start {
+		res[0].Range.Start = res[l].Range.Start
+		res = res[:l]
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package hash
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	c := NewConsistent()
+	c.Set([]string{"a", "b", "c", "d"})
+	elts := []string{"a", "c", "d", "e", "f"}
+	plan := c.Plan(elts)
+	if len(plan) == 0 {
+		t.Fatal("expected migrations")
+	}
+	if len(c.Members()) != 4 {
+		t.Fatal("Plan changed the circle")
+	}
+
+	next := c.Clone()
+	next.Set(elts)
+	for i := 0; i < 20000; i++ {
+		key := "key" + strconv.Itoa(i)
+		from, _ := c.Get(key)
+		to, _ := next.Get(key)
+		h := c.HashOf(key)
+		var found []Migration
+		for _, m := range plan {
+			if m.Range.Contains(h) {
+				found = append(found, m)
+			}
+		}
+		if from == to {
+			if len(found) != 0 {
+				t.Fatalf("%s stays on %s but is in %v", key, from, found)
+			}
+			continue
+		}
+		if len(found) != 1 || found[0].From != from || found[0].To != to {
+			t.Fatalf("%s moves %s -> %s but matches %v", key, from, to, found)
+		}
+	}
+	for _, m := range plan {
+		if m.From == "a" && m.To != "e" && m.To != "f" {
+			t.Errorf("unexpected move between kept members: %+v", m)
+		}
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	c := NewConsistent()
+	c.Set([]string{"a", "b"})
+	if plan := c.Plan([]string{"b", "a"}); len(plan) != 0 {
+		t.Errorf("expected no migrations, got %v", plan)
+	}
+	if plan := Diff(NewConsistent(), c); plan != nil {
+		t.Errorf("expected no migrations from an empty circle, got %v", plan)
+	}
+}
+
+func TestDiffConcurrent(t *testing.T) {
+	a, b := NewConsistent(), NewConsistent()
+	a.Set([]string{"a", "b", "c"})
+	b.Set([]string{"b", "c", "d"})
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*Consistent{{a, b}, {b, a}} {
+		from, to := pair[0], pair[1]
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				Diff(from, to)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				from.Add("x")
+				from.Remove("x")
+			}
+		}()
+	}
+	wg.Wait()
+}
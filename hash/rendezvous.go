@@ -0,0 +1,100 @@
+package hash
+
+import (
+	"sort"
+	"sync"
+)
+
+// Rendezvous implements highest random weight hashing.
+//
+// Every member scores every name and the highest score wins, so only the keys of
+// an added or removed member move. Lookups cost O(members).
+//
+// Read more: https://en.wikipedia.org/wiki/Rendezvous_hashing
+type Rendezvous struct {
+	members []string
+	hashes  []uint64
+	sync.RWMutex
+}
+
+// NewRendezvous creates a new Rendezvous object.
+func NewRendezvous() *Rendezvous {
+	return new(Rendezvous)
+}
+
+// Add inserts a member, it is ignored if elt is already a member.
+func (r *Rendezvous) Add(elt string) {
+	r.Lock()
+	defer r.Unlock()
+	if indexOf(r.members, elt) >= 0 {
+		return
+	}
+	r.members = append(r.members, elt)
+	r.hashes = append(r.hashes, hash64(elt))
+}
+
+// Remove removes a member.
+func (r *Rendezvous) Remove(elt string) {
+	r.Lock()
+	defer r.Unlock()
+	i := indexOf(r.members, elt)
+	if i < 0 {
+		return
+	}
+	r.members = append(r.members[:i], r.members[i+1:]...)
+	r.hashes = append(r.hashes[:i], r.hashes[i+1:]...)
+}
+
+// Members returns the members.
+func (r *Rendezvous) Members() []string {
+	r.RLock()
+	defer r.RUnlock()
+	return append([]string(nil), r.members...)
+}
+
+// Get returns the member with the highest score for name.
+func (r *Rendezvous) Get(name string) (string, error) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.members) == 0 {
+		return "", ErrEmptyCircle
+	}
+	key := hash64(name)
+	best, score := 0, uint64(0)
+	for i, h := range r.hashes {
+		if s := mix64(key ^ h); s > score || i == 0 {
+			best, score = i, s
+		}
+	}
+	return r.members[best], nil
+}
+
+// GetN returns the N members with the highest scores for name, best first.
+func (r *Rendezvous) GetN(name string, n int) ([]string, error) {
+	r.RLock()
+	defer r.RUnlock()
+	if len(r.members) == 0 {
+		return nil, ErrEmptyCircle
+	}
+	if n > len(r.members) {
+		n = len(r.members)
+	}
+	if n <= 0 {
+		return []string{}, nil
+	}
+	key := hash64(name)
+	idx := make([]int, len(r.members))
+	scores := make([]uint64, len(r.members))
+	for i, h := range r.hashes {
+		idx[i] = i
+		scores[i] = mix64(key ^ h)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		return scores[idx[a]] > scores[idx[b]]
+	})
+	res := make([]string, n)
+	for i := range res {
+		res[i] = r.members[idx[i]]
+	}
+	return res, nil
+}
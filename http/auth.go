@@ -0,0 +1,546 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator is an authentication scheme of the requests.
+type Authenticator interface {
+	Interceptor() Interceptor
+}
+
+// Auth authenticates the request with auth.
+//
+// example:
+//
+//	http.Get(url).Auth(http.NewDigestAuth("user", "pass")).String()
+func (b *Context) Auth(auth Authenticator) *Context {
+	return b.Intercept(auth.Interceptor())
+}
+
+// replayRequest returns a copy of req to send again, with the body rewound.
+func replayRequest(req *http.Request) (*http.Request, bool) {
+	if !replayable(req) {
+		return nil, false
+	}
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		r.Body = body
+	}
+	return r, true
+}
+
+// discard drains and closes the body of a response that is replaced.
+func discard(resp *http.Response) {
+	if resp.Body != nil {
+		io.CopyN(ioutil.Discard, resp.Body, 4096)
+		resp.Body.Close()
+	}
+}
+
+// DigestAuth is the HTTP Digest authentication, RFC 7616. The challenge of the
+// server is kept, so only the first request gets a 401 before being replayed.
+type DigestAuth struct {
+	username  string
+	password  string
+	challenge map[string]string
+	nc        uint32
+	sync.Mutex
+}
+
+// NewDigestAuth creates a DigestAuth for the user.
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{username: username, password: password}
+}
+
+// Interceptor implements Authenticator.
+func (d *DigestAuth) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		sent := req
+		if header, ok := d.authorization(req); ok {
+			sent = req.Clone(req.Context())
+			sent.Header.Set("Authorization", header)
+		}
+		resp, err := next(sent)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		challenge, ok := parseChallenge(resp.Header.Get("WWW-Authenticate"), "Digest")
+		if !ok {
+			return resp, nil
+		}
+		replay, ok := replayRequest(req)
+		if !ok {
+			return resp, nil
+		}
+		d.Lock()
+		d.challenge, d.nc = challenge, 0
+		d.Unlock()
+		header, ok := d.authorization(replay)
+		if !ok {
+			return resp, nil
+		}
+		discard(resp)
+		replay.Header.Set("Authorization", header)
+		return next(replay)
+	}
+}
+
+// authorization returns the Authorization header of req for the current challenge.
+func (d *DigestAuth) authorization(req *http.Request) (string, bool) {
+	d.Lock()
+	challenge := d.challenge
+	d.nc++
+	nc := d.nc
+	d.Unlock()
+	if challenge == nil {
+		return "", false
+	}
+	var newHash func() hash.Hash
+	algorithm := challenge["algorithm"]
+	switch strings.ToUpper(strings.TrimSuffix(algorithm, "-sess")) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", false
+	}
+	qop := ""
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var c [8]byte
+	rand.Read(c[:])
+	cnonce := hex.EncodeToString(c[:])
+	ncValue := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+	response := digestResponse(newHash, strings.HasSuffix(strings.ToLower(algorithm), "-sess"),
+		d.username, challenge["realm"], d.password, req.Method, uri,
+		challenge["nonce"], ncValue, cnonce, qop)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		d.username, challenge["realm"], challenge["nonce"], uri, response)
+	if algorithm != "" {
+		fmt.Fprintf(&buf, ", algorithm=%s", algorithm)
+	}
+	if qop != "" {
+		fmt.Fprintf(&buf, `, qop=%s, nc=%s, cnonce="%s"`, qop, ncValue, cnonce)
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		fmt.Fprintf(&buf, `, opaque="%s"`, opaque)
+	}
+	return buf.String(), true
+}
+
+// digestResponse computes the response of a digest challenge.
+func digestResponse(newHash func() hash.Hash, sess bool, username, realm, password, method, uri, nonce, nc, cnonce, qop string) string {
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	ha1 := h(username + ":" + realm + ":" + password)
+	if sess {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "" {
+		return h(ha1 + ":" + nonce + ":" + ha2)
+	}
+	return h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+}
+
+// parseChallenge parses the params of a WWW-Authenticate header of scheme.
+func parseChallenge(header, scheme string) (map[string]string, bool) {
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) || header[len(scheme)] != ' ' {
+		return nil, false
+	}
+	params := make(map[string]string)
+	s := header[len(scheme)+1:]
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			value = strings.Replace(s[1:end], `\`, "", -1)
+			s = s[end+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params, true
+}
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken string
+	TokenType   string // "Bearer" when empty
+	Expiry      time.Time
+}
+
+// Valid reports whether the token is set and does not expire within 10 seconds.
+func (t *Token) Valid() bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Until(t.Expiry) > 10*time.Second)
+}
+
+// TokenSource returns the tokens of a BearerAuth.
+// Invalidate is called when the server rejected the token, the next Token must return a new one.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+	Invalidate()
+}
+
+// staticToken is a TokenSource always returning the same token.
+type staticToken struct {
+	token *Token
+}
+
+// StaticToken returns a TokenSource of a fixed bearer token.
+func StaticToken(accessToken string) TokenSource {
+	return staticToken{&Token{AccessToken: accessToken}}
+}
+
+func (s staticToken) Token(ctx context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+func (s staticToken) Invalidate() {}
+
+// ClientCredentials is a TokenSource fetching tokens with the OAuth2
+// client credentials grant, RFC 6749 4.4. The token is cached until it expires.
+// The token requests are sent without the interceptors of the settings, so a
+// BearerAuth of the default settings does not authenticate them.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Client sends the token requests, nil means the default settings.
+	Client *Client
+	token  *Token
+	fetch  *tokenFetch
+	sync.Mutex
+}
+
+// tokenFetch is a token request in flight.
+type tokenFetch struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewClientCredentials creates a ClientCredentials token source.
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	}
+}
+
+// Token implements TokenSource, the concurrent callers wait for a single token request,
+// or until their ctx is done.
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c.Lock()
+	if c.token.Valid() {
+		token := c.token
+		c.Unlock()
+		return token, nil
+	}
+	if f := c.fetch; f != nil {
+		c.Unlock()
+		select {
+		case <-f.done:
+			return f.token, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &tokenFetch{done: make(chan struct{})}
+	c.fetch = f
+	c.Unlock()
+
+	f.token, f.err = c.request(ctx)
+	c.Lock()
+	if f.err == nil {
+		c.token = f.token
+	}
+	c.fetch = nil
+	c.Unlock()
+	close(f.done)
+	return f.token, f.err
+}
+
+// request sends a token request.
+func (c *ClientCredentials) request(ctx context.Context) (*Token, error) {
+	req := Post(c.TokenURL)
+	if c.Client != nil {
+		req = c.Client.Post(c.TokenURL)
+	}
+	req.setting.Interceptors = nil
+	req.WithContext(ctx).SetBasicAuth(c.ClientID, c.ClientSecret).
+		Header("Accept", "application/json").
+		Param("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		req.Param("scope", strings.Join(c.Scopes, " "))
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := req.ToJSON(&res); err != nil {
+		return nil, err
+	}
+	if res.AccessToken == "" {
+		return nil, errors.New("http: token response without access_token")
+	}
+	token := &Token{AccessToken: res.AccessToken, TokenType: res.TokenType}
+	if res.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// Invalidate implements TokenSource.
+func (c *ClientCredentials) Invalidate() {
+	c.Lock()
+	c.token = nil
+	c.Unlock()
+}
+
+// BearerAuth sets the token of its source as Authorization header. On a 401
+// the token is invalidated, and the request is sent once more with a new token.
+type BearerAuth struct {
+	source TokenSource
+}
+
+// NewBearerAuth creates a BearerAuth with the tokens of source.
+func NewBearerAuth(source TokenSource) *BearerAuth {
+	return &BearerAuth{source: source}
+}
+
+// Interceptor implements Authenticator.
+func (a *BearerAuth) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		resp, err := a.send(req, next)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		replay, ok := replayRequest(req)
+		if !ok {
+			return resp, nil
+		}
+		discard(resp)
+		a.source.Invalidate()
+		return a.send(replay, next)
+	}
+}
+
+func (a *BearerAuth) send(req *http.Request, next Handler) (*http.Response, error) {
+	token, err := a.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return next(r)
+}
+
+// HMACAuth signs the requests with HMAC-SHA256. The canonical request is
+//
+//	METHOD
+//	/escaped/path
+//	sorted=query&string=
+//	name:value of every signed header, lower case and sorted
+//	signed;header;names
+//	hex sha256 of the body
+//
+// and the Authorization header is
+//
+//	HMAC-SHA256 Credential=<key id>, SignedHeaders=<names>, Signature=<hex hmac of the string to sign>
+//
+// where the string to sign is "HMAC-SHA256\n<X-Date>\n<hex sha256 of the canonical request>".
+type HMACAuth struct {
+	KeyID  string
+	Secret []byte
+	// Headers are signed in addition to host and x-date.
+	Headers []string
+	now     func() time.Time
+}
+
+// HMACDateFormat is the format of the X-Date header of the signed requests.
+const HMACDateFormat = "20060102T150405Z"
+
+// NewHMACAuth creates a HMACAuth signing with secret.
+func NewHMACAuth(keyID string, secret []byte, headers ...string) *HMACAuth {
+	return &HMACAuth{KeyID: keyID, Secret: secret, Headers: headers, now: time.Now}
+}
+
+// Interceptor implements Authenticator.
+func (a *HMACAuth) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		if err := a.Sign(r); err != nil {
+			return nil, err
+		}
+		return next(r)
+	}
+}
+
+// Sign sets the X-Date and Authorization headers of req.
+func (a *HMACAuth) Sign(req *http.Request) error {
+	req.Header.Set("X-Date", a.now().UTC().Format(HMACDateFormat))
+	signed, signature, err := a.signature(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 Credential=%s, SignedHeaders=%s, Signature=%s",
+		a.KeyID, signed, signature))
+	return nil
+}
+
+// Verify checks the signature of a request signed by Sign, on the server side.
+func (a *HMACAuth) Verify(req *http.Request) error {
+	params, ok := parseChallenge(req.Header.Get("Authorization"), "HMAC-SHA256")
+	if !ok || params["credential"] != a.KeyID {
+		return errors.New("http: missing or foreign HMAC signature")
+	}
+	_, signature, err := a.signature(req)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(params["signature"])) {
+		return errors.New("http: invalid HMAC signature")
+	}
+	return nil
+}
+
+// signature returns the signed header names and the signature of req.
+func (a *HMACAuth) signature(req *http.Request) (string, string, error) {
+	payload := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", "", err
+		}
+		payload.Write(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	names := []string{"host", "x-date"}
+	for _, h := range a.Headers {
+		if h = strings.ToLower(h); h != "host" && h != "x-date" {
+			names = append(names, h)
+		}
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(req.URL.EscapedPath() + "\n")
+	canonical.WriteString(req.URL.Query().Encode() + "\n")
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		canonical.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString(signed + "\n")
+	canonical.WriteString(hex.EncodeToString(payload.Sum(nil)))
+
+	digest := sha256.Sum256([]byte(canonical.String()))
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write([]byte("HMAC-SHA256\n" + req.Header.Get("X-Date") + "\n" + hex.EncodeToString(digest[:])))
+	return signed, hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// MTLSConfig returns a tls config presenting the client certificate cert,
+// and trusting roots for the server, nil means the system roots.
+func MTLSConfig(cert tls.Certificate, roots *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+	}
+}
+
+// LoadMTLSConfig loads the client certificate and key PEM files, and the CA file of the
+// server if caFile is not empty. The config is set with SetTLSClientConfig or HTTPSettings.
+func LoadMTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var roots *x509.CertPool
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("http: no certificate found in %s", caFile)
+		}
+	}
+	return MTLSConfig(cert, roots), nil
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDigestResponse(t *testing.T) {
+	// RFC 2617 3.5
+	got := digestResponse(md5.New, false, "Mufasa", "testrealm@host.com", "Circle Of Life", "GET", "/dir/index.html",
+		"dcd98b7102dd2f0e8b11d0f600bfb0c093", "00000001", "0a4f113b", "auth")
+	if got != "6629fae49393a05397450978507c4ef1" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	const nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	var challenges int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, ok := parseChallenge(r.Header.Get("Authorization"), "Digest")
+		if ok {
+			want := digestResponse(md5.New, false, "user", "test", "pass", r.Method, r.URL.RequestURI(),
+				nonce, params["nc"], params["cnonce"], params["qop"])
+			if params["response"] == want && params["opaque"] == "o" {
+				w.Write([]byte("ok " + params["nc"]))
+				return
+			}
+		}
+		atomic.AddInt32(&challenges, 1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth,auth-int", nonce="`+nonce+`", opaque="o"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	auth := NewDigestAuth("user", "pass")
+	if body, err := Post(ts.URL + "/a?b=c").Body("data").Auth(auth).String(); err != nil || body != "ok 00000001" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	// the challenge is reused
+	if body, _ := Get(ts.URL).Auth(auth).String(); body != "ok 00000002" || challenges != 1 {
+		t.Errorf("got %q after %d challenges", body, challenges)
+	}
+}
+
+func TestBearerAuthRefresh(t *testing.T) {
+	var issued int32
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "id" || pass != "secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "a b" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokens.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first token is revoked
+		if r.Header.Get("Authorization") != "Bearer t2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer api.Close()
+
+	auth := NewBearerAuth(NewClientCredentials(tokens.URL, "id", "secret", "a", "b"))
+	if body, err := Get(api.URL).Auth(auth).String(); err != nil || body != "ok" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if body, _ := Get(api.URL).Auth(auth).String(); body != "ok" || issued != 2 {
+		t.Errorf("got %q with %d tokens issued", body, issued)
+	}
+
+	if resp, err := Get(api.URL).Auth(NewBearerAuth(StaticToken("bad"))).Response(); err != nil || resp.StatusCode != 401 {
+		t.Errorf("static token got %v, %v", resp, err)
+	}
+}
+
+func TestBearerAuthDefaultSetting(t *testing.T) {
+	release := make(chan struct{})
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic aWQ6c2VjcmV0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("scope") == "slow" {
+			<-release
+		}
+		w.Write([]byte(`{"access_token":"t1","expires_in":3600}`))
+	}))
+	defer tokens.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer api.Close()
+
+	// the token request does not go through the BearerAuth of the default settings
+	old := defaultSetting
+	defer SetDefaultSetting(old)
+	setting := old
+	setting.Interceptors = []Interceptor{NewBearerAuth(NewClientCredentials(tokens.URL, "id", "secret")).Interceptor()}
+	SetDefaultSetting(setting)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if body, err := Get(api.URL).WithContext(ctx).String(); err != nil || body != "Bearer t1" {
+		t.Errorf("got %q, %v", body, err)
+	}
+
+	// a caller waiting for the token request in flight gives up with its context
+	source := NewClientCredentials(tokens.URL, "id", "secret", "slow")
+	first := make(chan error, 1)
+	go func() {
+		_, err := source.Token(context.Background())
+		first <- err
+	}()
+	for i := 0; i < 100; i++ {
+		source.Lock()
+		fetching := source.fetch != nil
+		source.Unlock()
+		if fetching {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := source.Token(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	close(release)
+	if err := <-first; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	auth := NewHMACAuth("key", []byte("secret"), "Content-Type")
+	auth.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := auth.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Date")))
+	}))
+	defer ts.Close()
+
+	if body, err := Post(ts.URL+"/x").Param("q", "1").Body(`{"a":1}`).Header("Content-Type", "application/json").Auth(auth).String(); err != nil || body != "20240102T030405Z" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	tamper := func(req *http.Request, next Handler) (*http.Response, error) {
+		req.Header.Set("Content-Type", "text/plain")
+		return next(req)
+	}
+	resp, err := Post(ts.URL+"/x").Body("a").Header("Content-Type", "application/json").Auth(auth).Intercept(tamper).Response()
+	if err != nil || resp.StatusCode != 401 {
+		t.Errorf("a tampered request got %v, %v", resp, err)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected response %v", resp.Header)
+	}
+}
+
+func TestMTLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.String()))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	config := MTLSConfig(ts.TLS.Certificates[0], roots)
+	if body, err := Get(ts.URL).SetTLSClientConfig(config).String(); err != nil || body == "" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if _, err := Get(ts.URL).SetTLSClientConfig(&tls.Config{RootCAs: roots}).String(); err == nil {
+		t.Error("expected an error without client certificate")
+	}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestBuilder builds a request of a Batch, *Template is a RequestBuilder.
+type RequestBuilder interface {
+	Build() (*Context, error)
+}
+
+// RequestFunc is a func building a request, like func() (*Context, error) { return http.Get(url), nil }.
+type RequestFunc func() (*Context, error)
+
+// Build implements RequestBuilder.
+func (f RequestFunc) Build() (*Context, error) {
+	return f()
+}
+
+// BatchMode is the error handling of a Batch.
+type BatchMode int
+
+const (
+	// CollectAll sends every request and collects all the errors.
+	CollectAll BatchMode = iota
+	// FailFast cancels the requests left after the first error.
+	FailFast
+)
+
+// BatchResult is the result of a request of a Batch.
+type BatchResult struct {
+	Index    int
+	Response *http.Response // the body is already read into Body
+	Body     []byte
+	Err      error
+}
+
+// Batch sends requests with bounded concurrency.
+// A request fails on a transport error, or on an unexpected status when it uses Expect.
+//
+// example:
+//
+//	user := client.Template("GET", "https://api.example.com/users/{id}")
+//	builders := make([]http.RequestBuilder, len(ids))
+//	for i, id := range ids {
+//		builders[i] = user.PathParam("id", id)
+//	}
+//	results, err := client.Batch(10).Mode(http.FailFast).Timeout(5 * time.Second).Run(ctx, builders...)
+type Batch struct {
+	client      *Client
+	concurrency int
+	mode        BatchMode
+	timeout     time.Duration
+}
+
+// NewBatch creates a Batch running concurrency requests at once, 0 means one.
+func NewBatch(concurrency int) *Batch {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Batch{concurrency: concurrency}
+}
+
+// Batch creates a Batch whose requests use the transports of the client,
+// and its retry policy when they have none.
+func (c *Client) Batch(concurrency int) *Batch {
+	b := NewBatch(concurrency)
+	b.client = c
+	return b
+}
+
+// Mode sets the error handling, CollectAll by default.
+func (b *Batch) Mode(mode BatchMode) *Batch {
+	b.mode = mode
+	return b
+}
+
+// Timeout sets the deadline shared by all the requests, 0 means none.
+func (b *Batch) Timeout(timeout time.Duration) *Batch {
+	b.timeout = timeout
+	return b
+}
+
+// Run sends the requests and returns their results in the order of builders.
+// The error is the first one by index with CollectAll, and the one that stopped
+// the batch with FailFast, the requests not sent then fail with context.Canceled.
+// The contexts of the requests are replaced by ctx.
+func (b *Batch) Run(ctx context.Context, builders ...RequestBuilder) ([]BatchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if b.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	results := make([]BatchResult, len(builders))
+	var failed error
+	var once sync.Once
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.concurrency && w < len(builders); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = b.do(ctx, i, builders[i])
+				if results[i].Err != nil && b.mode == FailFast {
+					once.Do(func() {
+						failed = results[i].Err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	for i := range builders {
+		if ctx.Err() != nil {
+			results[i] = BatchResult{Index: i, Err: ctx.Err()}
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if failed != nil {
+		return results, failed
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return results, r.Err
+		}
+	}
+	return results, nil
+}
+
+func (b *Batch) do(ctx context.Context, i int, builder RequestBuilder) BatchResult {
+	res := BatchResult{Index: i}
+	if res.Err = ctx.Err(); res.Err != nil {
+		return res
+	}
+	req, err := builder.Build()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if b.client != nil {
+		req.pool = b.client.pool
+		if req.setting.RetryPolicy == nil && req.setting.Retries == 0 {
+			req.setting.RetryPolicy = b.client.setting.RetryPolicy
+			req.setting.Retries = b.client.setting.Retries
+		}
+	}
+	req.WithContext(ctx)
+	res.Body, res.Err = req.Bytes()
+	res.Response = req.res
+	if res.Response != nil && res.Response.StatusCode == 0 {
+		res.Response = nil
+	}
+	return res
+}
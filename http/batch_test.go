@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	var running, peak, sent int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		defer atomic.AddInt32(&running, -1)
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(r.URL.Path[1:]))
+	}))
+	defer ts.Close()
+
+	client := NewClient(HTTPSettings{ExpectStatus: Status2xx})
+	tpl := client.Template("GET", ts.URL+"/{id}")
+	builders := make([]RequestBuilder, 20)
+	for i := range builders {
+		builders[i] = tpl.PathParam("id", strconv.Itoa(i))
+	}
+	results, err := client.Batch(4).Run(context.Background(), builders...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Index != i || string(r.Body) != strconv.Itoa(i) || r.Response.StatusCode != 200 {
+			t.Errorf("result %d: %d %q %v", i, r.Index, r.Body, r.Err)
+		}
+	}
+	if n := atomic.LoadInt32(&peak); n > 4 {
+		t.Errorf("%d requests ran at once", n)
+	}
+	if stats := client.Stats(); stats.Transports != 1 || stats.Requests != 20 {
+		t.Errorf("the client pool was not shared: %+v", stats)
+	}
+
+	// CollectAll keeps going after the failure
+	atomic.StoreInt32(&sent, 0)
+	builders[3] = tpl.URL(ts.URL + "/fail")
+	results, err = client.Batch(2).Run(context.Background(), builders...)
+	var herr *HTTPError
+	if n := atomic.LoadInt32(&sent); !errors.As(err, &herr) || results[3].Err == nil || n != 20 {
+		t.Errorf("collect all got %v, %d sent", err, n)
+	}
+
+	// FailFast cancels the rest
+	atomic.StoreInt32(&sent, 0)
+	results, err = client.Batch(2).Mode(FailFast).Run(context.Background(), builders...)
+	if n := atomic.LoadInt32(&sent); !errors.As(err, &herr) || n >= 20 || !errors.Is(results[19].Err, context.Canceled) {
+		t.Errorf("fail fast got %v, %d sent, last %v", err, n, results[19].Err)
+	}
+
+	// the deadline is shared by the whole batch
+	slow := make([]RequestBuilder, 6)
+	for i := range slow {
+		slow[i] = RequestFunc(func() (*Context, error) {
+			return Get(ts.URL+"/x").Param("slow", "1"), nil
+		})
+	}
+	start := time.Now()
+	results, err = NewBatch(2).Timeout(300*time.Millisecond).Run(context.Background(), slow...)
+	if err == nil || time.Since(start) > time.Second || results[0].Err != nil || !errors.Is(results[5].Err, context.DeadlineExceeded) {
+		t.Errorf("deadline got %v after %s, first %v, last %v", err, time.Since(start), results[0].Err, results[5].Err)
+	}
+}
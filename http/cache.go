@@ -0,0 +1,248 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ueffort/goutils/cache"
+)
+
+// CacheHeader is set to "HIT" on the responses served from a ResponseCache,
+// and to "REVALIDATED" when the server answered 304 Not Modified.
+const CacheHeader = "X-From-Cache"
+
+// cacheableStatuses are the status codes cacheable by default, RFC 7231 6.1.
+var cacheableStatuses = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// ResponseCache is a private http cache storing the GET responses in a cache.Store.
+// It honors Cache-Control, Expires and Vary, and revalidates stale responses
+// with If-None-Match and If-Modified-Since.
+//
+// example:
+//
+//	rc := http.NewResponseCache(cache.MemoryCache().Store("http"))
+//	http.Get(url).Intercept(rc.Interceptor()).ToJSON(&v)
+type ResponseCache struct {
+	store cache.Store
+	// ForceTTL is the freshness of the responses without Cache-Control max-age or Expires,
+	// 0 means they are only stored when they can be revalidated.
+	ForceTTL time.Duration
+	// Key returns the key of a request in the store, nil means the method and the url.
+	Key func(req *http.Request) string
+	now func() time.Time
+}
+
+// NewResponseCache creates a ResponseCache on store.
+func NewResponseCache(store cache.Store) *ResponseCache {
+	return &ResponseCache{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// cacheEntry is a stored response.
+type cacheEntry struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Expires    time.Time
+	Vary       map[string]string // the request headers named by Vary
+}
+
+// Interceptor returns the interceptor serving the requests from the cache.
+func (c *ResponseCache) Interceptor() Interceptor {
+	return c.intercept
+}
+
+func (c *ResponseCache) intercept(req *http.Request, next Handler) (*http.Response, error) {
+	if req.Method != "GET" || hasDirective(req.Header, "no-store") {
+		return next(req)
+	}
+	key := c.key(req)
+	entry := c.load(key, req)
+	if entry != nil && c.now().Before(entry.Expires) && !hasDirective(req.Header, "no-cache") {
+		return entry.response(req, "HIT"), nil
+	}
+
+	sent := req
+	if entry != nil {
+		etag, modified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			sent = req.Clone(req.Context())
+			if etag != "" && sent.Header.Get("If-None-Match") == "" {
+				sent.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" && sent.Header.Get("If-Modified-Since") == "" {
+				sent.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+	resp, err := next(sent)
+	if err != nil {
+		return resp, err
+	}
+
+	if entry != nil && resp.StatusCode == http.StatusNotModified && sent != req {
+		resp.Body.Close()
+		// the 304 refreshes the headers of the stored response
+		for k, v := range resp.Header {
+			entry.Header[k] = v
+		}
+		entry.Expires = c.expires(entry.Header)
+		c.save(key, entry)
+		return entry.response(req, "REVALIDATED"), nil
+	}
+	if !cacheableStatuses[resp.StatusCode] || hasDirective(resp.Header, "no-store") {
+		return resp, nil
+	}
+	vary := resp.Header.Values("Vary")
+	for _, v := range vary {
+		if strings.TrimSpace(v) == "*" {
+			return resp, nil
+		}
+	}
+	expires := c.expires(resp.Header)
+	if !expires.After(c.now()) && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry = &cacheEntry{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Expires:    expires,
+	}
+	for _, v := range vary {
+		for _, name := range strings.Split(v, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				if entry.Vary == nil {
+					entry.Vary = make(map[string]string)
+				}
+				entry.Vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	c.save(key, entry)
+	return resp, nil
+}
+
+func (c *ResponseCache) key(req *http.Request) string {
+	if c.Key != nil {
+		return c.Key(req)
+	}
+	return req.Method + " " + req.URL.String()
+}
+
+// load returns the entry of key if it matches the Vary headers of req.
+func (c *ResponseCache) load(key string, req *http.Request) *cacheEntry {
+	data, ok := c.store.Get(key)
+	if !ok || len(data) == 0 {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if req.Header.Get(name) != value {
+			return nil
+		}
+	}
+	return entry
+}
+
+func (c *ResponseCache) save(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = c.store.Set(key, data)
+	}
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// expires returns the end of the freshness of a response with header h.
+func (c *ResponseCache) expires(h http.Header) time.Time {
+	now := c.now()
+	if hasDirective(h, "no-cache") {
+		return now
+	}
+	if maxAge, ok := directive(h, "max-age"); ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return now
+		}
+		if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+			seconds -= age
+		}
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return now
+		}
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			// use the clock of the server
+			return now.Add(expires.Sub(date))
+		}
+		return expires
+	}
+	return now.Add(c.ForceTTL)
+}
+
+// response builds the response of req from the entry.
+func (e *cacheEntry) response(req *http.Request, state string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheHeader, state)
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// directive returns the value of a Cache-Control directive.
+func directive(h http.Header, name string) (string, bool) {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			value := ""
+			if i := strings.IndexByte(d, '='); i >= 0 {
+				d, value = d[:i], strings.Trim(d[i+1:], `"`)
+			}
+			if strings.EqualFold(d, name) {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasDirective(h http.Header, name string) bool {
+	_, ok := directive(h, name)
+	return ok
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ueffort/goutils/cache"
+)
+
+func TestResponseCache(t *testing.T) {
+	var hits, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "X-Lang")
+		}
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Lang")))
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	rc := NewResponseCache(cache.MemoryCache().Store("http"))
+	rc.now = func() time.Time { return now }
+	get := func(path string, lang ...string) (string, string) {
+		req := Get(ts.URL + path).Intercept(rc.Interceptor())
+		if len(lang) > 0 {
+			req.Header("X-Lang", lang[0])
+		}
+		body, err := req.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, req.res.Header.Get(CacheHeader)
+	}
+	count := func() int32 {
+		return atomic.SwapInt32(&hits, 0)
+	}
+
+	get("/max-age")
+	if body, state := get("/max-age"); body != "/max-age " || state != "HIT" {
+		t.Errorf("got %q %q", body, state)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("max-age sent %d requests", n)
+	}
+	now = now.Add(61 * time.Second)
+	if _, state := get("/max-age"); state != "" {
+		t.Errorf("a stale response was served: %q", state)
+	}
+	count()
+
+	get("/etag")
+	if body, state := get("/etag"); body != "/etag " || state != "REVALIDATED" || notModified != 1 {
+		t.Errorf("got %q %q %d", body, state, notModified)
+	}
+
+	get("/no-store")
+	if _, state := get("/no-store"); state != "" {
+		t.Errorf("no-store was cached: %q", state)
+	}
+
+	get("/vary", "en")
+	if body, state := get("/vary", "fr"); body != "/vary fr" || state != "" {
+		t.Errorf("vary got %q %q", body, state)
+	}
+	if body, state := get("/vary", "fr"); body != "/vary fr" || state != "HIT" {
+		t.Errorf("vary got %q %q", body, state)
+	}
+
+	rc.ForceTTL = time.Minute
+	get("/plain")
+	if _, state := get("/plain"); state != "HIT" {
+		t.Errorf("ForceTTL was not applied: %q", state)
+	}
+}
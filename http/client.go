@@ -0,0 +1,371 @@
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPool holds the transports of the requests not created by a Client.
+var defaultPool = NewTransportPool()
+
+// Client creates requests sharing its settings and the connections of its transport pool.
+type Client struct {
+	setting HTTPSettings
+	pool    *TransportPool
+}
+
+// NewClient creates a Client with its own transport pool.
+// With EnableCookie and no CookieJar, the client gets its own in memory CookieJar.
+func NewClient(setting HTTPSettings) *Client {
+	setting.register()
+	if setting.EnableCookie && setting.CookieJar == nil {
+		setting.CookieJar = NewCookieJar()
+	}
+	return &Client{
+		setting: setting,
+		pool:    NewTransportPool(),
+	}
+}
+
+// Setting returns the settings of the requests created by the client.
+func (c *Client) Setting() HTTPSettings {
+	return c.setting
+}
+
+// NewRequest creates a request using the settings and the transports of the client.
+func (c *Client) NewRequest(rawurl, method string) *Context {
+	b := NewRequest(rawurl, method)
+	b.setting = c.setting
+	b.pool = c.pool
+	return b
+}
+
+func (c *Client) Get(url string) *Context {
+	return c.NewRequest(url, "GET")
+}
+
+func (c *Client) Post(url string) *Context {
+	return c.NewRequest(url, "POST")
+}
+
+func (c *Client) Put(url string) *Context {
+	return c.NewRequest(url, "PUT")
+}
+
+func (c *Client) Delete(url string) *Context {
+	return c.NewRequest(url, "DELETE")
+}
+
+func (c *Client) Head(url string) *Context {
+	return c.NewRequest(url, "HEAD")
+}
+
+// CookieJar returns the cookie jar of the client, nil when the cookies are not enabled.
+func (c *Client) CookieJar() http.CookieJar {
+	if !c.setting.EnableCookie {
+		return nil
+	}
+	return c.setting.CookieJar
+}
+
+// Stats returns the connection statistics of the client.
+func (c *Client) Stats() PoolStats {
+	return c.pool.Stats()
+}
+
+// CloseIdleConnections closes the idle connections of the client.
+func (c *Client) CloseIdleConnections() {
+	c.pool.CloseIdleConnections()
+}
+
+// DefaultPoolStats returns the connection statistics of the requests not created by a Client.
+func DefaultPoolStats() PoolStats {
+	return defaultPool.Stats()
+}
+
+// PoolStats are the connection statistics of a TransportPool.
+type PoolStats struct {
+	Transports  int   // transports created for distinct settings
+	Requests    int64 // requests that got a connection
+	NewConns    int64 // connections dialed
+	ReusedConns int64 // requests sent over a kept-alive connection
+	OpenConns   int64 // connections dialed and not closed yet, idle or in use
+}
+
+// maxPoolTransports is the number of transports kept by a TransportPool.
+const maxPoolTransports = 64
+
+// TransportPool shares one http.Transport between the requests with the same
+// transport settings, so their connections are kept alive and reused.
+// The least recently used transport is closed when the pool is full.
+type TransportPool struct {
+	transports map[transportKey]*list.Element
+	lru        *list.List
+	max        int
+	sync.Mutex
+}
+
+// NewTransportPool creates an empty TransportPool.
+func NewTransportPool() *TransportPool {
+	return &TransportPool{
+		transports: make(map[transportKey]*list.Element),
+		lru:        list.New(),
+		max:        maxPoolTransports,
+	}
+}
+
+// funcIDs identify the Proxy and DialContext funcs of a setting registered once,
+// 0 when the func is not registered. Funcs are not comparable, so a transport
+// is only shared for registered funcs.
+type funcIDs struct {
+	proxy uint64
+	dial  uint64
+}
+
+var lastFuncID uint64
+
+func nextFuncID() uint64 {
+	return atomic.AddUint64(&lastFuncID, 1)
+}
+
+// register identifies the funcs of the setting, done by NewClient, SetDefaultSetting
+// and Template.Setting whose settings are shared by many requests.
+func (s *HTTPSettings) register() {
+	s.funcs = funcIDs{}
+	if s.Proxy != nil {
+		s.funcs.proxy = nextFuncID()
+	}
+	if s.DialContext != nil {
+		s.funcs.dial = nextFuncID()
+	}
+}
+
+// pooled reports whether the transport of the setting can be shared.
+func (s *HTTPSettings) pooled() bool {
+	return (s.Proxy == nil || s.funcs.proxy != 0) && (s.DialContext == nil || s.funcs.dial != 0)
+}
+
+// transportKey holds the settings a transport is built from.
+type transportKey struct {
+	connectTimeout        time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	maxIdleConns          int
+	maxIdleConnsPerHost   int
+	idleConnTimeout       time.Duration
+	tls                   string
+	tlsConfig             *tls.Config // the configs without a fingerprint are keyed by identity
+	funcs                 funcIDs
+}
+
+func newTransportKey(setting HTTPSettings) transportKey {
+	key := transportKey{
+		connectTimeout:        setting.ConnectTimeout,
+		tlsHandshakeTimeout:   setting.TLSHandshakeTimeout,
+		responseHeaderTimeout: setting.ResponseHeaderTimeout,
+		maxIdleConns:          setting.MaxIdleConns,
+		maxIdleConnsPerHost:   setting.MaxIdleConnsPerHost,
+		idleConnTimeout:       setting.IdleConnTimeout,
+		funcs:                 setting.funcs,
+	}
+	if key.responseHeaderTimeout == 0 {
+		key.responseHeaderTimeout = setting.ReadWriteTimeout
+	}
+	key.tls, key.tlsConfig = tlsFingerprint(setting.TLSClientConfig)
+	return key
+}
+
+// tlsFingerprint returns a fingerprint of the values of config, so the equal configs
+// created for every request share a transport. A config holding callbacks or other
+// values only comparable by identity is returned instead.
+func tlsFingerprint(config *tls.Config) (string, *tls.Config) {
+	if config == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.PkgPath != "" || value.IsZero() {
+			continue
+		}
+		switch {
+		case field.Name == "RootCAs" || field.Name == "ClientCAs":
+			fmt.Fprintf(&buf, "%s=%p;", field.Name, value.Interface())
+		case field.Name == "Certificates":
+			h := sha256.New()
+			for _, cert := range config.Certificates {
+				for _, der := range cert.Certificate {
+					h.Write(der)
+				}
+			}
+			fmt.Fprintf(&buf, "%s=%x;", field.Name, h.Sum(nil))
+		case basicKind(value.Kind()),
+			(value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && basicKind(value.Type().Elem().Kind()):
+			fmt.Fprintf(&buf, "%s=%v;", field.Name, value.Interface())
+		default:
+			return "", config
+		}
+	}
+	return buf.String(), nil
+}
+
+func basicKind(kind reflect.Kind) bool {
+	return kind >= reflect.Bool && kind <= reflect.Complex128 || kind == reflect.String
+}
+
+// Transport returns the shared transport for setting, creating it on first use.
+// A setting with a Proxy or DialContext func not registered by NewClient,
+// SetDefaultSetting, SetProxyRules or Template.Setting gets a transport of its
+// own, with keep-alives disabled.
+func (p *TransportPool) Transport(setting HTTPSettings) http.RoundTripper {
+	if !setting.pooled() {
+		t := &http.Transport{DisableKeepAlives: true}
+		applySetting(t, setting)
+		return t
+	}
+	key := newTransportKey(setting)
+	p.Lock()
+	defer p.Unlock()
+	if e, ok := p.transports[key]; ok {
+		p.lru.MoveToFront(e)
+		return e.Value.(*pooledTransport)
+	}
+	t := &pooledTransport{key: key}
+	t.Transport = &http.Transport{
+		MaxIdleConns:        setting.MaxIdleConns,
+		MaxIdleConnsPerHost: setting.MaxIdleConnsPerHost,
+		IdleConnTimeout:     setting.IdleConnTimeout,
+		DialContext:         t.dialContext(setting),
+	}
+	applySetting(t.Transport, setting)
+	p.transports[key] = p.lru.PushFront(t)
+	for p.lru.Len() > p.max {
+		e := p.lru.Back()
+		p.lru.Remove(e)
+		evicted := e.Value.(*pooledTransport)
+		delete(p.transports, evicted.key)
+		evicted.evict()
+	}
+	return t
+}
+
+// Stats sums the statistics of all transports of the pool.
+func (p *TransportPool) Stats() PoolStats {
+	p.Lock()
+	defer p.Unlock()
+	stats := PoolStats{Transports: len(p.transports)}
+	for _, e := range p.transports {
+		t := e.Value.(*pooledTransport)
+		stats.Requests += atomic.LoadInt64(&t.requests)
+		stats.NewConns += atomic.LoadInt64(&t.newConns)
+		stats.ReusedConns += atomic.LoadInt64(&t.reusedConns)
+		stats.OpenConns += atomic.LoadInt64(&t.openConns)
+	}
+	return stats
+}
+
+// CloseIdleConnections closes the idle connections of all transports of the pool.
+func (p *TransportPool) CloseIdleConnections() {
+	p.Lock()
+	defer p.Unlock()
+	for _, e := range p.transports {
+		e.Value.(*pooledTransport).CloseIdleConnections()
+	}
+}
+
+// pooledTransport counts the connections of a shared transport.
+type pooledTransport struct {
+	// the counters come first to be 64-bit aligned for atomic access
+	requests    int64
+	newConns    int64
+	reusedConns int64
+	openConns   int64
+	evicted     int32
+	key         transportKey
+	*http.Transport
+}
+
+// evict closes the idle connections of a transport removed from its pool,
+// and those released by the requests in flight.
+func (t *pooledTransport) evict() {
+	atomic.StoreInt32(&t.evicted, 1)
+	t.CloseIdleConnections()
+}
+
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddInt64(&t.requests, 1)
+			if info.Reused {
+				atomic.AddInt64(&t.reusedConns, 1)
+			}
+		},
+	}
+	resp, err := t.Transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, err
+	}
+	resp.Body = &evictBody{resp.Body, t}
+	return resp, nil
+}
+
+// evictBody closes the connection of a response body once released, when its
+// transport has been evicted.
+type evictBody struct {
+	io.ReadCloser
+	t *pooledTransport
+}
+
+func (b *evictBody) Close() error {
+	err := b.ReadCloser.Close()
+	if atomic.LoadInt32(&b.t.evicted) == 1 {
+		b.t.CloseIdleConnections()
+	}
+	return err
+}
+
+func (t *pooledTransport) dialContext(setting HTTPSettings) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := setting.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   setting.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&t.newConns, 1)
+		atomic.AddInt64(&t.openConns, 1)
+		return &countedConn{Conn: conn, open: &t.openConns}, nil
+	}
+}
+
+// countedConn decrements the open connections counter once when closed.
+type countedConn struct {
+	net.Conn
+	open   *int64
+	closed int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.open, -1)
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientReusesConnections(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(defaultSetting)
+	for i := 0; i < 5; i++ {
+		if body, err := client.Get(ts.URL).String(); err != nil || body != "ok" {
+			t.Fatalf("got %q, %v", body, err)
+		}
+	}
+	stats := client.Stats()
+	if stats.Transports != 1 || stats.Requests != 5 || stats.NewConns != 1 || stats.ReusedConns != 4 || stats.OpenConns != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// other transport settings get their own transport
+	client.Get(ts.URL).SetTimeout(time.Second, time.Second).String()
+	if stats = client.Stats(); stats.Transports != 2 || stats.NewConns != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	client.CloseIdleConnections()
+	if stats = client.Stats(); stats.OpenConns != 0 {
+		t.Errorf("%d connections still open", stats.OpenConns)
+	}
+}
+
+// connCounter counts the connections of a test server still open.
+func connCounter(ts *httptest.Server) *int32 {
+	var open int32
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt32(&open, 1)
+		case http.StateClosed, http.StateHijacked:
+			atomic.AddInt32(&open, -1)
+		}
+	}
+	return &open
+}
+
+func waitConns(open *int32, want int32) bool {
+	for i := 0; i < 100 && atomic.LoadInt32(open) != want; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return atomic.LoadInt32(open) == want
+}
+
+func TestPoolPerRequestSettings(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	open := connCounter(ts)
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := NewClient(HTTPSettings{})
+	for i := 0; i < 50; i++ {
+		if _, err := client.Get(ts.URL).SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).String(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := client.Stats(); stats.Transports != 1 || stats.NewConns != 1 {
+		t.Errorf("equal tls configs got %+v", stats)
+	}
+
+	direct := func(*http.Request) (*url.URL, error) { return nil, nil }
+	for i := 0; i < 50; i++ {
+		req := client.Get(ts.URL).SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).SetProxy(direct)
+		if _, err := req.String(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := client.Stats(); stats.Transports != 1 {
+		t.Errorf("unregistered proxies got %+v", stats)
+	}
+	if !waitConns(open, 1) {
+		t.Errorf("%d connections open", atomic.LoadInt32(open))
+	}
+
+	rules, _ := NewProxyRules("http://proxy", "*")
+	for i := 0; i < 5; i++ {
+		client.Get(ts.URL).SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).SetProxyRules(rules).String()
+	}
+	if stats := client.Stats(); stats.Transports != 2 || stats.NewConns != 2 {
+		t.Errorf("proxy rules got %+v", stats)
+	}
+
+	verify := &tls.Config{VerifyConnection: func(tls.ConnectionState) error { return nil }}
+	if fp, config := tlsFingerprint(verify); fp != "" || config != verify {
+		t.Error("a config with callbacks is keyed by identity")
+	}
+	a, _ := tlsFingerprint(&tls.Config{ServerName: "a", NextProtos: []string{"h2"}})
+	b, _ := tlsFingerprint(&tls.Config{ServerName: "b", NextProtos: []string{"h2"}})
+	if a == b || a == "" {
+		t.Errorf("fingerprints %q and %q", a, b)
+	}
+}
+
+func TestPoolEviction(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	open := connCounter(ts)
+	ts.Start()
+	defer ts.Close()
+
+	client := NewClient(HTTPSettings{})
+	client.pool.max = 2
+	for i := 1; i <= 3; i++ {
+		if _, err := client.Get(ts.URL).SetTimeout(time.Duration(i)*time.Second, time.Second).String(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := client.Stats(); stats.Transports != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if !waitConns(open, 2) {
+		t.Errorf("%d connections open", atomic.LoadInt32(open))
+	}
+
+	// a transport evicted while its request is in flight closes the connection once released
+	resp, err := client.Get(ts.URL).SetTimeout(4*time.Second, time.Second).Response()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Get(ts.URL).SetTimeout(5*time.Second, time.Second).String()
+	client.Get(ts.URL).SetTimeout(6*time.Second, time.Second).String()
+	resp.Body.Close()
+	if !waitConns(open, 2) {
+		t.Errorf("%d connections open after eviction", atomic.LoadInt32(open))
+	}
+}
@@ -0,0 +1,280 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ueffort/goutils/cache"
+)
+
+// CookieJar is a http.CookieJar whose cookies can be listed and cleared by domain,
+// and optionally persisted to a file or a cache.Store after every change.
+// Session cookies are persisted too, so a session survives a restart.
+type CookieJar struct {
+	jar     *cookiejar.Jar
+	cookies map[string]*jarCookie
+	load    func() ([]byte, error)
+	save    func(data []byte) error
+	sync.Mutex
+}
+
+// jarCookie is a cookie with the url that set it.
+type jarCookie struct {
+	URL    string
+	Domain string
+	Cookie *http.Cookie
+}
+
+// NewCookieJar creates an empty in memory CookieJar.
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &CookieJar{
+		jar:     jar,
+		cookies: make(map[string]*jarCookie),
+	}
+}
+
+// NewFileCookieJar creates a CookieJar persisted to filename, loading the cookies it holds.
+func NewFileCookieJar(filename string) (*CookieJar, error) {
+	j := NewCookieJar()
+	j.load = func() ([]byte, error) {
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	}
+	j.save = func(data []byte) error {
+		return ioutil.WriteFile(filename, data, 0600)
+	}
+	return j, j.restore()
+}
+
+// NewStoreCookieJar creates a CookieJar persisted under key in store, loading the cookies it holds.
+func NewStoreCookieJar(store cache.Store, key string) (*CookieJar, error) {
+	j := NewCookieJar()
+	j.load = func() ([]byte, error) {
+		data, _ := store.Get(key)
+		return data, nil
+	}
+	j.save = func(data []byte) error {
+		return store.Set(key, data)
+	}
+	return j, j.restore()
+}
+
+// SetCookieJar sets the cookie jar of the request and enables the cookies.
+func (b *Context) SetCookieJar(jar http.CookieJar) *Context {
+	b.setting.CookieJar = jar
+	b.setting.EnableCookie = true
+	return b
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Lock()
+	defer j.Unlock()
+	j.setCookies(u, cookies, time.Now())
+	j.persist()
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.Lock()
+	defer j.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// DomainCookies returns the cookies of domain and its subdomains.
+func (j *CookieJar) DomainCookies(domain string) []*http.Cookie {
+	j.Lock()
+	defer j.Unlock()
+	now := time.Now()
+	var res []*http.Cookie
+	for _, c := range j.sorted() {
+		if matchDomain(c.Domain, domain) && !expired(c.Cookie, now) {
+			cookie := *c.Cookie
+			res = append(res, &cookie)
+		}
+	}
+	return res
+}
+
+// Domains returns the domains holding cookies.
+func (j *CookieJar) Domains() []string {
+	j.Lock()
+	defer j.Unlock()
+	seen := make(map[string]bool)
+	var res []string
+	for _, c := range j.sorted() {
+		if !seen[c.Domain] {
+			seen[c.Domain] = true
+			res = append(res, c.Domain)
+		}
+	}
+	return res
+}
+
+// Clear removes the cookies of domain and its subdomains.
+func (j *CookieJar) Clear(domain string) {
+	j.Lock()
+	defer j.Unlock()
+	for key, c := range j.cookies {
+		if matchDomain(c.Domain, domain) {
+			delete(j.cookies, key)
+		}
+	}
+	j.rebuild()
+	j.persist()
+}
+
+// ClearAll removes all the cookies.
+func (j *CookieJar) ClearAll() {
+	j.Lock()
+	defer j.Unlock()
+	j.cookies = make(map[string]*jarCookie)
+	j.rebuild()
+	j.persist()
+}
+
+// setCookies records the cookies set by u accepted by the inner jar, with absolute expiry times.
+func (j *CookieJar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	for _, c := range cookies {
+		domain, ok := cookieDomain(u, c.Domain)
+		if !ok {
+			continue
+		}
+		cookie := *c
+		if !strings.HasPrefix(cookie.Path, "/") {
+			cookie.Path = defaultPath(u.Path)
+		}
+		if cookie.MaxAge > 0 {
+			cookie.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			cookie.MaxAge = 0
+		}
+		key := cookieKey(domain, &cookie)
+		if expired(&cookie, now) {
+			delete(j.cookies, key)
+		} else {
+			j.cookies[key] = &jarCookie{URL: u.String(), Domain: domain, Cookie: &cookie}
+		}
+		j.jar.SetCookies(u, []*http.Cookie{&cookie})
+	}
+}
+
+// cookieDomain returns the domain of a cookie set by u, false when cookiejar
+// rejects it: a domain attribute must be the host or one of its parents.
+func cookieDomain(u *url.URL, domain string) (string, bool) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if domain == "" {
+		return host, true
+	}
+	if net.ParseIP(host) != nil {
+		return host, domain == host
+	}
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false
+	}
+	return domain, host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// defaultPath returns the default path of the cookies set by a request to path (RFC 6265 section 5.1.4).
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// rebuild replaces the inner jar with one holding the recorded cookies.
+func (j *CookieJar) rebuild() {
+	j.jar, _ = cookiejar.New(nil)
+	for _, c := range j.sorted() {
+		if u, err := url.Parse(c.URL); err == nil {
+			j.jar.SetCookies(u, []*http.Cookie{c.Cookie})
+		}
+	}
+}
+
+func (j *CookieJar) restore() error {
+	data, err := j.load()
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	var cookies []*jarCookie
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, c := range cookies {
+		if c.Cookie != nil && !expired(c.Cookie, now) {
+			if u, err := url.Parse(c.URL); err == nil && !strings.HasPrefix(c.Cookie.Path, "/") {
+				c.Cookie.Path = defaultPath(u.Path)
+			}
+			j.cookies[cookieKey(c.Domain, c.Cookie)] = c
+		}
+	}
+	j.rebuild()
+	return nil
+}
+
+// persist saves the cookies when the jar is persistent.
+func (j *CookieJar) persist() {
+	if j.save == nil {
+		return
+	}
+	data, err := json.Marshal(j.sorted())
+	if err == nil {
+		err = j.save(data)
+	}
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// sorted returns the recorded cookies in a stable order.
+func (j *CookieJar) sorted() []*jarCookie {
+	res := make([]*jarCookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if res[a].Domain != res[b].Domain {
+			return res[a].Domain < res[b].Domain
+		}
+		if res[a].Cookie.Path != res[b].Cookie.Path {
+			return res[a].Cookie.Path < res[b].Cookie.Path
+		}
+		return res[a].Cookie.Name < res[b].Cookie.Name
+	})
+	return res
+}
+
+func cookieKey(domain string, c *http.Cookie) string {
+	return domain + ";" + c.Path + ";" + c.Name
+}
+
+func expired(c *http.Cookie, now time.Time) bool {
+	return c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(now))
+}
+
+// matchDomain reports whether cookieDomain is domain or one of its subdomains.
+func matchDomain(cookieDomain, domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	return cookieDomain == domain || strings.HasSuffix(cookieDomain, "."+domain)
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ueffort/goutils/cache"
+)
+
+func cookieServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get("set"); v != "" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: v, MaxAge: 3600})
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+}
+
+func TestClientCookieJar(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	a := NewClient(HTTPSettings{EnableCookie: true})
+	b := NewClient(HTTPSettings{EnableCookie: true})
+	a.Get(ts.URL + "?set=a").Bytes()
+	b.Get(ts.URL + "?set=b").Bytes()
+	if body, _ := a.Get(ts.URL).String(); body != "a" {
+		t.Errorf("client a got %q", body)
+	}
+	if body, _ := b.Get(ts.URL).String(); body != "b" {
+		t.Errorf("client b got %q", body)
+	}
+
+	jar := a.CookieJar().(*CookieJar)
+	if domains := jar.Domains(); len(domains) != 1 || domains[0] != "127.0.0.1" {
+		t.Errorf("unexpected domains %v", domains)
+	}
+	if cookies := jar.DomainCookies("127.0.0.1"); len(cookies) != 1 || cookies[0].Value != "a" {
+		t.Errorf("unexpected cookies %v", cookies)
+	}
+	jar.Clear("127.0.0.1")
+	if body, _ := a.Get(ts.URL).String(); body != "" {
+		t.Errorf("the cleared jar sent %q", body)
+	}
+}
+
+func TestPersistentCookieJar(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Get(ts.URL + "?set=file").SetCookieJar(jar).Bytes()
+	restored, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := Get(ts.URL).SetCookieJar(restored).String(); body != "file" {
+		t.Errorf("restored file jar sent %q", body)
+	}
+
+	store := cache.MemoryCache().Store("cookies")
+	jar, _ = NewStoreCookieJar(store, "user1")
+	Get(ts.URL + "?set=store").SetCookieJar(jar).Bytes()
+	restored, err = NewStoreCookieJar(store, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(ts.URL)
+	if cookies := restored.Cookies(u); len(cookies) != 1 || cookies[0].Value != "store" {
+		t.Errorf("restored store jar has %v", cookies)
+	}
+	restored.ClearAll()
+	if again, _ := NewStoreCookieJar(store, "user1"); len(again.Domains()) != 0 {
+		t.Errorf("ClearAll was not persisted: %v", again.Domains())
+	}
+}
+
+func TestCookieJarRejected(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("https://www.evil.com/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "steal", Value: "1", Domain: "bank.com"},
+		{Name: "dot", Value: "1", Domain: "evil.com."},
+		{Name: "own", Value: "1", Domain: ".evil.com"},
+	})
+	if domains := jar.Domains(); len(domains) != 1 || domains[0] != "evil.com" {
+		t.Errorf("unexpected domains %v", domains)
+	}
+	if cookies := jar.DomainCookies("bank.com"); len(cookies) != 0 {
+		t.Errorf("rejected cookies listed %v", cookies)
+	}
+	if data, _ := ioutil.ReadFile(filename); strings.Contains(string(data), "bank.com") {
+		t.Errorf("rejected cookie persisted: %s", data)
+	}
+	ip, _ := url.Parse("http://127.0.0.1/")
+	jar.SetCookies(ip, []*http.Cookie{{Name: "ip", Value: "1", Domain: "127.0.0.2"}})
+	if cookies := jar.DomainCookies("127.0.0.2"); len(cookies) != 0 {
+		t.Errorf("rejected cookies listed %v", cookies)
+	}
+}
+
+func TestCookieJarDefaultPath(t *testing.T) {
+	jar, err := NewFileCookieJar(filepath.Join(t.TempDir(), "cookies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := func(rawurl string, cookie *http.Cookie) {
+		u, _ := url.Parse(rawurl)
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+	set("https://a.com/shop/cart", &http.Cookie{Name: "id", Value: "1"})
+	set("https://a.com/shop/list", &http.Cookie{Name: "id", Value: "2", Path: "/shop"})
+	set("https://a.com/account/me", &http.Cookie{Name: "id", Value: "3"})
+	set("https://a.com", &http.Cookie{Name: "root", Value: "4"})
+	cookies := jar.DomainCookies("a.com")
+	if len(cookies) != 3 {
+		t.Fatalf("got %v", cookies)
+	}
+	if cookies[0].Path != "/" || cookies[1].Path != "/account" || cookies[2].Path != "/shop" || cookies[2].Value != "2" {
+		t.Errorf("unexpected cookies %v", cookies)
+	}
+	set("https://a.com/shop/other", &http.Cookie{Name: "id", MaxAge: -1})
+	if cookies := jar.DomainCookies("a.com"); len(cookies) != 2 {
+		t.Errorf("the cookie was not deleted: %v", cookies)
+	}
+}
@@ -0,0 +1,206 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Decoder returns the decoded stream of a body with a content encoding.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// Encoder returns a writer encoding into w, closing it flushes the encoding.
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// encodings holds the content codings, the decoders are offered in Accept-Encoding in order.
+var encodings = struct {
+	names    []string
+	decoders map[string]Decoder
+	encoders map[string]Encoder
+	sync.RWMutex
+}{
+	names: []string{"gzip", "deflate"},
+	decoders: map[string]Decoder{
+		"gzip":    decodeGzip,
+		"x-gzip":  decodeGzip,
+		"deflate": decodeDeflate,
+	},
+	encoders: map[string]Encoder{
+		"gzip": func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		"deflate": func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	},
+}
+
+// RegisterDecoder adds the decoder of a content encoding, offered in Accept-Encoding
+// when HTTPSettings.Gzip is set. Only gzip and deflate are built in: brotli is not
+// supported unless a decoder from a brotli package is registered.
+//
+// example:
+//
+//	http.RegisterDecoder("br", func(r io.Reader) (io.ReadCloser, error) {
+//		return ioutil.NopCloser(brotli.NewReader(r)), nil
+//	})
+func RegisterDecoder(encoding string, decoder Decoder) {
+	encodings.Lock()
+	defer encodings.Unlock()
+	encoding = strings.ToLower(encoding)
+	if _, ok := encodings.decoders[encoding]; !ok {
+		encodings.names = append(encodings.names, encoding)
+	}
+	encodings.decoders[encoding] = decoder
+}
+
+// RegisterEncoder adds the encoder of a content encoding, used to compress the requests.
+func RegisterEncoder(encoding string, encoder Encoder) {
+	encodings.Lock()
+	defer encodings.Unlock()
+	encodings.encoders[strings.ToLower(encoding)] = encoder
+}
+
+// Compress compresses the request body with encoding when it has at least minSize bytes.
+func (b *Context) Compress(encoding string, minSize int64) *Context {
+	b.setting.CompressRequest = encoding
+	b.setting.CompressMinSize = minSize
+	return b
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// decodeDeflate reads zlib streams, and the raw deflate streams sent by some servers.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// acceptEncoding returns the Accept-Encoding header of the registered decoders.
+func acceptEncoding() string {
+	encodings.RLock()
+	defer encodings.RUnlock()
+	return strings.Join(encodings.names, ", ")
+}
+
+// decodeHandler decodes the responses of next, so every reader of the response,
+// the interceptors included, gets the decoded body.
+func decodeHandler(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+		if err = decodeResponse(resp); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// decodeResponse decodes the body with the codings of the Content-Encoding header,
+// applied in order so decoded in reverse. The decoders are created on the first
+// read, like the transport does for gzip, so an empty body is not an error.
+func decodeResponse(resp *http.Response) error {
+	header := resp.Header.Get("Content-Encoding")
+	if header == "" || resp.Body == nil || resp.Body == http.NoBody ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		resp.Request != nil && resp.Request.Method == "HEAD" {
+		return nil
+	}
+	body := resp.Body
+	codings := strings.Split(header, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+		encodings.RLock()
+		decoder, ok := encodings.decoders[coding]
+		encodings.RUnlock()
+		if !ok {
+			resp.Body.Close()
+			return fmt.Errorf("http: unsupported content encoding %q", coding)
+		}
+		body = &lazyDecoder{decoder: decoder, body: body}
+	}
+	// the body is not encoded anymore, like the transport does for gzip
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// compressBody compresses the request body when it is large enough.
+// The bodies of unknown length (ContentLength -1, such as streamed readers) are sent uncompressed.
+func (b *Context) compressBody() error {
+	encoding := strings.ToLower(b.setting.CompressRequest)
+	if encoding == "" || b.req.Body == nil || b.req.Body == http.NoBody ||
+		b.req.ContentLength < b.setting.CompressMinSize || b.req.ContentLength <= 0 ||
+		b.req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	encodings.RLock()
+	encoder, ok := encodings.encoders[encoding]
+	encodings.RUnlock()
+	if !ok {
+		return fmt.Errorf("http: unsupported content encoding %q", encoding)
+	}
+	var buf bytes.Buffer
+	w, err := encoder(&buf)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, b.req.Body)
+	b.req.Body.Close()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+	b.setBody(buf.Bytes())
+	b.req.Header.Set("Content-Encoding", encoding)
+	return nil
+}
+
+// lazyDecoder decodes body with a decoder created on the first read.
+type lazyDecoder struct {
+	decoder Decoder
+	body    io.ReadCloser
+	r       io.ReadCloser
+	err     error
+}
+
+func (l *lazyDecoder) Read(p []byte) (int, error) {
+	if l.r == nil && l.err == nil {
+		l.r, l.err = l.decoder(l.body)
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.r.Read(p)
+}
+
+// Close closes the decoder then the body.
+func (l *lazyDecoder) Close() error {
+	if l.r != nil {
+		l.r.Close()
+	}
+	return l.body.Close()
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentEncoding(t *testing.T) {
+	const text = "hello hello hello hello"
+	encode := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		}
+		w.Write([]byte(text))
+		w.Close()
+		return buf.Bytes()
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		switch r.URL.Path {
+		case "/gzip", "/deflate":
+			w.Header().Set("Content-Encoding", r.URL.Path[1:])
+			w.Write(encode(r.URL.Path[1:]))
+		case "/raw":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(encode("raw"))
+		case "/empty":
+			w.Header().Set("Content-Encoding", "gzip")
+			if r.Header.Get("If-None-Match") != "" {
+				w.WriteHeader(http.StatusNotModified)
+			} else if r.Method != "HEAD" {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case "/unknown":
+			w.Header().Set("Content-Encoding", "compress")
+			w.Write([]byte(text))
+		}
+	}))
+	defer ts.Close()
+
+	for _, path := range []string{"/gzip", "/deflate", "/raw"} {
+		req := Get(ts.URL + path)
+		if body, err := req.String(); err != nil || body != text {
+			t.Errorf("%s got %q, %v", path, body, err)
+		}
+		if got := req.res.Header.Get("X-Accept-Encoding"); !strings.HasPrefix(got, "gzip, deflate") {
+			t.Errorf("%s sent Accept-Encoding %q", path, got)
+		}
+		name := filepath.Join(t.TempDir(), "out")
+		if err := Get(ts.URL + path).ToFile(name); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := ioutil.ReadFile(name); string(data) != text {
+			t.Errorf("%s ToFile wrote %q", path, data)
+		}
+	}
+	// Response and the interceptors get the decoded body too
+	var seen string
+	peek := func(req *http.Request, next Handler) (*http.Response, error) {
+		resp, err := next(req)
+		if err == nil {
+			seen = resp.Header.Get("Content-Encoding")
+		}
+		return resp, err
+	}
+	resp, err := Get(ts.URL + "/gzip").Intercept(peek).Response()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(resp.Body); string(data) != text || seen != "" {
+		t.Errorf("Response got %q with encoding %q", data, seen)
+	}
+	resp.Body.Close()
+	// the bodiless responses are not decoded
+	for _, req := range []*Context{
+		Get(ts.URL + "/empty"),
+		NewRequest(ts.URL+"/empty", "HEAD"),
+		Get(ts.URL+"/empty").Header("If-None-Match", `"v1"`),
+	} {
+		if body, err := req.String(); err != nil || body != "" {
+			t.Errorf("%s got %q, %v", req.req.Method, body, err)
+		}
+	}
+	if _, err := Get(ts.URL + "/unknown").Bytes(); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+	// without Gzip the body is left encoded
+	req := Get(ts.URL+"/gzip").Header("Accept-Encoding", "gzip")
+	req.setting.Gzip = false
+	if data, _ := req.Bytes(); !bytes.Equal(data, encode("gzip")) {
+		t.Error("the body was decoded without Gzip")
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder("x-upper", func(r io.Reader) (io.ReadCloser, error) {
+		data, err := ioutil.ReadAll(r)
+		return ioutil.NopCloser(strings.NewReader(strings.ToLower(string(data)))), err
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "x-upper") {
+			return
+		}
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write([]byte("HELLO"))
+		gw.Close()
+		w.Header().Set("Content-Encoding", "x-upper, gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	if body, err := Get(ts.URL).String(); err != nil || body != "hello" {
+		t.Errorf("got %q, %v", body, err)
+	}
+}
+
+func TestCompressRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		data, _ := ioutil.ReadAll(body)
+		w.Write([]byte(r.Header.Get("Content-Encoding") + ":" + string(data)))
+	}))
+	defer ts.Close()
+
+	large := strings.Repeat("a", 100)
+	if body, _ := Post(ts.URL).Body(large).Compress("gzip", 50).String(); body != "gzip:"+large {
+		t.Errorf("got %q", body)
+	}
+	if body, _ := Post(ts.URL).Body("small").Compress("gzip", 50).String(); body != ":small" {
+		t.Errorf("got %q", body)
+	}
+	// the compressed body is replayed by the retries
+	attempts := 0
+	flaky := func(req *http.Request, next Handler) (*http.Response, error) {
+		if attempts++; attempts == 1 {
+			return newResponse(req, http.StatusServiceUnavailable, nil, nil), nil
+		}
+		return next(req)
+	}
+	policy := NewRetryPolicy(1)
+	policy.Methods = []string{"*"}
+	policy.MinDelay = 0
+	body, _ := Post(ts.URL).Body(large).Compress("gzip", 50).SetRetryPolicy(policy).Intercept(flaky).String()
+	if body != "gzip:"+large || attempts != 2 {
+		t.Errorf("got %q after %d attempts", body, attempts)
+	}
+}
@@ -2,10 +2,11 @@ package http
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"hash"
 	"io"
 	"io/ioutil"
 	"log"
@@ -13,20 +14,24 @@ import (
 	"net"
 	"net/http"
 	"net/http/cookiejar"
-	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 var defaultSetting = HTTPSettings{
-	UserAgent:        "goutils web",
-	ConnectTimeout:   60 * time.Second,
-	ReadWriteTimeout: 60 * time.Second,
-	Gzip:             true,
-	DumpBody:         true,
+	UserAgent:           "goutils web",
+	ConnectTimeout:      60 * time.Second,
+	ReadWriteTimeout:    60 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 10,
+	IdleConnTimeout:     90 * time.Second,
+	Gzip:                true,
+	DumpBody:            true,
 }
 
 var defaultCookieJar http.CookieJar
@@ -43,6 +48,7 @@ func createDefaultCookie() {
 func SetDefaultSetting(setting HTTPSettings) {
 	settingMutex.Lock()
 	defer settingMutex.Unlock()
+	setting.register()
 	defaultSetting = setting
 }
 
@@ -63,9 +69,10 @@ func NewRequest(rawurl, method string) *Context {
 	return &Context{
 		url:     rawurl,
 		req:     &req,
-		params:  map[string][]string{},
-		files:   map[string]string{},
+		params:  []param{},
+		files:   []*filePart{},
 		setting: defaultSetting,
+		pool:    defaultPool,
 		res:     &res,
 	}
 }
@@ -80,30 +87,55 @@ func Post(url string) *Context {
 
 // web.Client setting
 type HTTPSettings struct {
-	ShowDebug        bool
-	UserAgent        string
-	ConnectTimeout   time.Duration
-	ReadWriteTimeout time.Duration
-	TLSClientConfig  *tls.Config
-	Proxy            func(*http.Request) (*url.URL, error)
-	Transport        http.RoundTripper
-	CheckRedirect    func(req *http.Request, via []*http.Request) error
-	EnableCookie     bool
-	Gzip             bool
-	DumpBody         bool
-	Retries          int // if set to -1 means will retry forever
+	ShowDebug      bool
+	UserAgent      string
+	ConnectTimeout time.Duration // dial timeout
+	// ReadWriteTimeout is the ResponseHeaderTimeout when that is not set.
+	ReadWriteTimeout      time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // time to wait for the response headers after writing the request
+	Timeout               time.Duration // total time of a request including retries and reading the body
+	MaxIdleConns          int           // idle connections kept by a pooled transport, 0 means no limit
+	MaxIdleConnsPerHost   int           // idle connections kept per host, 0 means 2 and -1 disables keep-alive reuse
+	IdleConnTimeout       time.Duration // time an idle connection is kept, 0 means no limit
+	TLSClientConfig       *tls.Config
+	Proxy                 func(*http.Request) (*url.URL, error)
+	DialContext           func(ctx context.Context, network, addr string) (net.Conn, error) // dials the servers and the proxies, nil means a net.Dialer with ConnectTimeout
+	Transport             http.RoundTripper
+	CheckRedirect         func(req *http.Request, via []*http.Request) error
+	EnableCookie          bool
+	CookieJar             http.CookieJar // the jar of EnableCookie, nil means the jar shared by the process
+	Gzip                  bool           // negotiates Accept-Encoding and decodes the responses with the registered decoders
+	CompressRequest       string         // content encoding of the request bodies of known length, "" means none
+	CompressMinSize       int64          // the smaller request bodies are not compressed
+	DumpBody              bool
+	Retries               int // if set to -1 means will retry forever
+	RetryPolicy           RetryPolicy
+	Interceptors          []Interceptor
+	ExpectStatus          StatusMatcher // the body readers fail with an *HTTPError for other status codes
+	ErrorDecoder          ErrorDecoder
+
+	funcs funcIDs // identities of the registered Proxy and DialContext
 }
 
 // 一个请求上下文
 type Context struct {
 	url     string
+	ctx     context.Context
 	req     *http.Request
-	params  map[string][]string
-	files   map[string]string
+	params  []param
+	files   []*filePart
 	setting HTTPSettings
+	pool    *TransportPool
 	res     *http.Response
 	body    []byte
 	dump    []byte
+
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
+	resume           bool
+	checksum         hash.Hash
+	checksumSum      string
 }
 
 // 获取request对象
@@ -111,9 +143,17 @@ func (b *Context) GetRequest() *http.Request {
 	return b.req
 }
 
+// WithContext sets the context of the request, its deadline and cancellation
+// apply to sending the request, retries and reading the body.
+func (b *Context) WithContext(ctx context.Context) *Context {
+	b.ctx = ctx
+	return b
+}
+
 // 设置请求
 func (b *Context) Setting(setting HTTPSettings) *Context {
 	b.setting = setting
+	b.setting.funcs = funcIDs{}
 	return b
 }
 
@@ -145,11 +185,29 @@ func (b *Context) Debug(isdebug bool) *Context {
 // default is 0 means no retried.
 // -1 means retried forever.
 // others means retried times.
+// Without a RetryPolicy it retries every method with the backoff of NewRetryPolicy.
 func (b *Context) Retries(times int) *Context {
 	b.setting.Retries = times
 	return b
 }
 
+// SetRetryPolicy sets the policy deciding when and after which delay the request is sent again,
+// it takes precedence over Retries. The streamed bodies that cannot be rewound are not
+// sent again: the failed request returns ErrNotReplayable and the failed response is logged.
+func (b *Context) SetRetryPolicy(policy RetryPolicy) *Context {
+	b.setting.RetryPolicy = policy
+	return b
+}
+
+// Intercept appends interceptors to the chain of this request,
+// they run after the ones of the settings.
+func (b *Context) Intercept(interceptors ...Interceptor) *Context {
+	all := make([]Interceptor, 0, len(b.setting.Interceptors)+len(interceptors))
+	all = append(all, b.setting.Interceptors...)
+	b.setting.Interceptors = append(all, interceptors...)
+	return b
+}
+
 // DumpBody setting whether need to Dump the Body.
 func (b *Context) DumpBody(isdump bool) *Context {
 	b.setting.DumpBody = isdump
@@ -168,6 +226,15 @@ func (b *Context) SetTimeout(connectTimeout, readWriteTimeout time.Duration) *Co
 	return b
 }
 
+// SetPhaseTimeouts sets the timeouts of the TLS handshake, of waiting for the
+// response headers and of the whole request. Zero means no timeout.
+func (b *Context) SetPhaseTimeouts(tlsHandshake, responseHeader, total time.Duration) *Context {
+	b.setting.TLSHandshakeTimeout = tlsHandshake
+	b.setting.ResponseHeaderTimeout = responseHeader
+	b.setting.Timeout = total
+	return b
+}
+
 // SetTLSClientConfig sets tls connection configurations if visiting https url.
 func (b *Context) SetTLSClientConfig(config *tls.Config) *Context {
 	b.setting.TLSClientConfig = config
@@ -216,14 +283,16 @@ func (b *Context) SetTransport(transport http.RoundTripper) *Context {
 }
 
 // SetProxy set the web api
+// The request gets a transport of its own, not kept alive, SetProxyRules shares one.
 // example:
 //
 //	func(req *web.Request) (*url.URL, error) {
-// 		u, _ := url.ParseRequestURI("http://127.0.0.1:8118")
-// 		return u, nil
-// 	}
+//		u, _ := url.ParseRequestURI("http://127.0.0.1:8118")
+//		return u, nil
+//	}
 func (b *Context) SetProxy(proxy func(*http.Request) (*url.URL, error)) *Context {
 	b.setting.Proxy = proxy
+	b.setting.funcs.proxy = 0
 	return b
 }
 
@@ -237,38 +306,49 @@ func (b *Context) SetCheckRedirect(redirect func(req *http.Request, via []*http.
 }
 
 // Param adds query param in to request.
-// params build query string as ?key1=value1&key2=value2...
+// params build query string as ?key1=value1&key2=value2... in the order they were added.
 func (b *Context) Param(key, value string) *Context {
-	if param, ok := b.params[key]; ok {
-		b.params[key] = append(param, value)
-	} else {
-		b.params[key] = []string{value}
-	}
+	b.params = append(b.params, param{key, value})
 	return b
 }
 
 // PostFile add a post file to the request
 func (b *Context) PostFile(formname, filename string) *Context {
-	b.files[formname] = filename
+	b.files = append(b.files, &filePart{formname: formname, filename: filename})
+	return b
+}
+
+// PostReader adds a multipart file part whose content is read from r.
+// If r is an io.Closer it is closed once sent.
+func (b *Context) PostReader(formname, filename string, r io.Reader) *Context {
+	b.files = append(b.files, &filePart{formname: formname, filename: filename, reader: r})
 	return b
 }
 
 // Body adds request raw body.
-// it supports string and []byte.
+// it supports string, []byte and io.Reader, a reader is streamed and can only
+// be sent again by retries if it is an io.Seeker.
 func (b *Context) Body(data interface{}) *Context {
 	switch t := data.(type) {
 	case string:
-		bf := bytes.NewBufferString(t)
-		b.req.Body = ioutil.NopCloser(bf)
-		b.req.ContentLength = int64(len(t))
+		b.setBody([]byte(t))
 	case []byte:
-		bf := bytes.NewBuffer(t)
-		b.req.Body = ioutil.NopCloser(bf)
-		b.req.ContentLength = int64(len(t))
+		b.setBody(t)
+	case io.Reader:
+		b.setBodyReader(t)
 	}
 	return b
 }
 
+// setBody sets a body that can be replayed by retries and redirects.
+func (b *Context) setBody(data []byte) {
+	b.req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	b.req.Body, _ = b.req.GetBody()
+	b.req.ContentLength = int64(len(data))
+}
+
 // JSONBody adds request raw body encoding by JSON.
 func (b *Context) JSONBody(obj interface{}) (*Context, error) {
 	if b.req.Body == nil && obj != nil {
@@ -276,14 +356,13 @@ func (b *Context) JSONBody(obj interface{}) (*Context, error) {
 		if err != nil {
 			return b, err
 		}
-		b.req.Body = ioutil.NopCloser(bytes.NewReader(byts))
-		b.req.ContentLength = int64(len(byts))
+		b.setBody(byts)
 		b.req.Header.Set("Content-Type", "application/json")
 	}
 	return b, nil
 }
 
-func (b *Context) buildURL(paramBody string) {
+func (b *Context) buildURL(paramBody string) error {
 	// build GET url with query string
 	if b.req.Method == "GET" && len(paramBody) > 0 {
 		if strings.Contains(b.url, "?") {
@@ -291,43 +370,34 @@ func (b *Context) buildURL(paramBody string) {
 		} else {
 			b.url = b.url + "?" + paramBody
 		}
-		return
+		return nil
 	}
 
 	// build POST/PUT/PATCH url and body
 	if (b.req.Method == "POST" || b.req.Method == "PUT" || b.req.Method == "PATCH" || b.req.Method == "DELETE") && b.req.Body == nil {
 		// with files
 		if len(b.files) > 0 {
+			// open the files now so a missing file fails the request
+			for _, part := range b.files {
+				if part.reader != nil {
+					continue
+				}
+				fh, err := os.Open(part.filename)
+				if err != nil {
+					b.closeFiles()
+					return err
+				}
+				part.reader = fh
+			}
 			pr, pw := io.Pipe()
 			bodyWriter := multipart.NewWriter(pw)
 			go func() {
-				for formname, filename := range b.files {
-					fileWriter, err := bodyWriter.CreateFormFile(formname, filename)
-					if err != nil {
-						log.Println("Httplib:", err)
-					}
-					fh, err := os.Open(filename)
-					if err != nil {
-						log.Println("Httplib:", err)
-					}
-					//iocopy
-					_, err = io.Copy(fileWriter, fh)
-					fh.Close()
-					if err != nil {
-						log.Println("Httplib:", err)
-					}
-				}
-				for k, v := range b.params {
-					for _, vv := range v {
-						bodyWriter.WriteField(k, vv)
-					}
-				}
-				bodyWriter.Close()
-				pw.Close()
+				pw.CloseWithError(b.writeMultipart(bodyWriter))
 			}()
 			b.Header("Content-Type", bodyWriter.FormDataContentType())
-			b.req.Body = ioutil.NopCloser(pr)
-			return
+			// closing the body on a failed request stops the writer
+			b.req.Body = pr
+			return nil
 		}
 
 		// with params
@@ -336,6 +406,7 @@ func (b *Context) buildURL(paramBody string) {
 			b.Body(paramBody)
 		}
 	}
+	return nil
 }
 
 func (b *Context) getResponse() (*http.Response, error) {
@@ -355,19 +426,23 @@ func (b *Context) DoRequest() (resp *http.Response, err error) {
 	var paramBody string
 	if len(b.params) > 0 {
 		var buf bytes.Buffer
-		for k, v := range b.params {
-			for _, vv := range v {
-				buf.WriteString(url.QueryEscape(k))
-				buf.WriteByte('=')
-				buf.WriteString(url.QueryEscape(vv))
-				buf.WriteByte('&')
-			}
+		for _, p := range b.params {
+			buf.WriteString(url.QueryEscape(p.key))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(p.value))
+			buf.WriteByte('&')
 		}
 		paramBody = buf.String()
 		paramBody = paramBody[0 : len(paramBody)-1]
 	}
 
-	b.buildURL(paramBody)
+	if err = b.buildURL(paramBody); err != nil {
+		return nil, err
+	}
+	if err = b.compressBody(); err != nil {
+		return nil, err
+	}
+	b.wrapUpload()
 	url, err := url.Parse(b.url)
 	if err != nil {
 		return nil, err
@@ -378,34 +453,24 @@ func (b *Context) DoRequest() (resp *http.Response, err error) {
 	trans := b.setting.Transport
 
 	if trans == nil {
-		// create default transport
-		trans = &http.Transport{
-			TLSClientConfig:     b.setting.TLSClientConfig,
-			Proxy:               b.setting.Proxy,
-			Dial:                TimeoutDialer(b.setting.ConnectTimeout, b.setting.ReadWriteTimeout),
-			MaxIdleConnsPerHost: -1,
-		}
+		// share the transport of the requests with the same settings
+		trans = b.pool.Transport(b.setting)
 	} else {
 		// if b.transport is *web.Transport then set the settings.
 		if t, ok := trans.(*http.Transport); ok {
-			if t.TLSClientConfig == nil {
-				t.TLSClientConfig = b.setting.TLSClientConfig
-			}
-			if t.Proxy == nil {
-				t.Proxy = b.setting.Proxy
-			}
-			if t.Dial == nil {
-				t.Dial = TimeoutDialer(b.setting.ConnectTimeout, b.setting.ReadWriteTimeout)
-			}
+			applySetting(t, b.setting)
 		}
 	}
 
 	var jar http.CookieJar
 	if b.setting.EnableCookie {
-		if defaultCookieJar == nil {
-			createDefaultCookie()
+		jar = b.setting.CookieJar
+		if jar == nil {
+			if defaultCookieJar == nil {
+				createDefaultCookie()
+			}
+			jar = defaultCookieJar
 		}
-		jar = defaultCookieJar
 	}
 
 	client := &http.Client{
@@ -416,30 +481,102 @@ func (b *Context) DoRequest() (resp *http.Response, err error) {
 	if b.setting.UserAgent != "" && b.req.Header.Get("User-Agent") == "" {
 		b.req.Header.Set("User-Agent", b.setting.UserAgent)
 	}
+	if b.setting.Gzip && b.req.Header.Get("Accept-Encoding") == "" {
+		b.req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
 
 	if b.setting.CheckRedirect != nil {
 		client.CheckRedirect = b.setting.CheckRedirect
 	}
 
+	interceptors := b.setting.Interceptors
 	if b.setting.ShowDebug {
-		dump, err := httputil.DumpRequest(b.req, b.setting.DumpBody)
-		if err != nil {
-			log.Println(err.Error())
-		}
-		b.dump = dump
+		dump := DumpInterceptor(b.setting.DumpBody, func(dump []byte) {
+			b.dump = dump
+		})
+		interceptors = append([]Interceptor{dump}, interceptors...)
+	}
+	send := client.Do
+	if b.setting.Gzip {
+		send = decodeHandler(send)
 	}
+	handler := Chain(send, interceptors...)
+
 	// retries default value is 0, it will run once.
 	// retries equal to -1, it will run forever until success
 	// retries is setted, it will retries fixed times.
-	for i := 0; b.setting.Retries == -1 || i <= b.setting.Retries; i++ {
-		resp, err = client.Do(b.req)
-		if err == nil {
-			break
+	policy := b.setting.RetryPolicy
+	if policy == nil && b.setting.Retries != 0 {
+		p := NewRetryPolicy(b.setting.Retries)
+		p.Methods = []string{"*"}
+		policy = p
+	}
+
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if b.setting.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.setting.Timeout)
+	}
+	b.req = b.req.WithContext(ctx)
+	resp, err = doRetry(b.req, handler, policy)
+	if err != nil && b.req.Body != nil {
+		// the interceptors may fail without sending the body
+		b.req.Body.Close()
+	}
+	if cancel != nil {
+		if err != nil || resp.Body == nil {
+			cancel()
+		} else {
+			// the timeout keeps running until the body is closed
+			resp.Body = &cancelBody{resp.Body, cancel}
 		}
 	}
 	return resp, err
 }
 
+// applySetting fills the fields of t that are not set from setting.
+func applySetting(t *http.Transport, setting HTTPSettings) {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = setting.TLSClientConfig
+	}
+	if t.Proxy == nil {
+		t.Proxy = setting.Proxy
+	}
+	if t.Dial == nil && t.DialContext == nil && setting.DialContext != nil {
+		t.DialContext = setting.DialContext
+	}
+	if t.Dial == nil && t.DialContext == nil {
+		t.DialContext = (&net.Dialer{
+			Timeout:   setting.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if t.TLSHandshakeTimeout == 0 {
+		t.TLSHandshakeTimeout = setting.TLSHandshakeTimeout
+	}
+	if t.ResponseHeaderTimeout == 0 {
+		t.ResponseHeaderTimeout = setting.ResponseHeaderTimeout
+		if t.ResponseHeaderTimeout == 0 {
+			t.ResponseHeaderTimeout = setting.ReadWriteTimeout
+		}
+	}
+}
+
+// cancelBody releases the context of a request when its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
 // String returns the body string in response.
 // it calls Response inner.
 func (b *Context) String() (string, error) {
@@ -457,51 +594,91 @@ func (b *Context) Bytes() ([]byte, error) {
 	if b.body != nil {
 		return b.body, nil
 	}
-	resp, err := b.getResponse()
+	body, err := b.Stream()
 	if err != nil {
 		return nil, err
 	}
-	if resp.Body == nil {
+	if body == nil {
 		return nil, nil
 	}
-	defer resp.Body.Close()
-	if b.setting.Gzip && resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		b.body, err = ioutil.ReadAll(reader)
-		return b.body, err
-	}
-	b.body, err = ioutil.ReadAll(resp.Body)
+	defer body.Close()
+	b.body, err = ioutil.ReadAll(body)
 	return b.body, err
 }
 
 // ToFile saves the body data in response to one file.
 // it calls Response inner.
+// With Resume an existing file is completed with a Range request, with Checksum
+// the whole file is verified once written.
 func (b *Context) ToFile(filename string) error {
-	f, err := os.Create(filename)
+	var offset int64
+	if b.resume {
+		// the ranges of an encoded body do not match the file
+		if b.req.Header.Get("Accept-Encoding") == "" {
+			b.req.Header.Set("Accept-Encoding", "identity")
+		}
+		if info, err := os.Stat(filename); err == nil && info.Size() > 0 {
+			offset = info.Size()
+			b.req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		}
+	}
+
+	resp, err := b.getResponse()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the file is already complete
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return b.verifyFile(filename, nil)
+	default:
+		offset = 0
+	}
 
-	resp, err := b.getResponse()
+	body, err := b.stream(offset)
 	if err != nil {
 		return err
 	}
-	if resp.Body == nil {
+	if body != nil {
+		defer body.Close()
+	}
+	f, err := os.OpenFile(filename, flag, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if body == nil {
 		return nil
 	}
-	defer resp.Body.Close()
-	_, err = io.Copy(f, resp.Body)
-	return err
+	if b.checksum != nil {
+		b.checksum.Reset()
+		if offset > 0 {
+			// the checksum covers the part downloaded before
+			if err = hashFile(b.checksum, filename, offset); err != nil {
+				return err
+			}
+		}
+		_, err = io.Copy(io.MultiWriter(f, b.checksum), body)
+	} else {
+		_, err = io.Copy(f, body)
+	}
+	if err != nil {
+		return err
+	}
+	return b.verifyFile(filename, b.checksum)
 }
 
 // ToJSON returns the map that marshals from the body bytes as json in response .
+// it fails with an *HTTPError for a status other than 2xx, unless Expect is set.
 // it calls Response inner.
 func (b *Context) ToJSON(v interface{}) error {
-	data, err := b.Bytes()
+	data, err := b.expectedBytes(Status2xx)
 	if err != nil {
 		return err
 	}
@@ -509,9 +686,10 @@ func (b *Context) ToJSON(v interface{}) error {
 }
 
 // ToXML returns the map that marshals from the body bytes as xml in response .
+// it fails with an *HTTPError for a status other than 2xx, unless Expect is set.
 // it calls Response inner.
 func (b *Context) ToXML(v interface{}) error {
-	data, err := b.Bytes()
+	data, err := b.expectedBytes(Status2xx)
 	if err != nil {
 		return err
 	}
@@ -524,6 +702,9 @@ func (b *Context) Response() (*http.Response, error) {
 }
 
 // TimeoutDialer returns functions of connection dialer with timeout settings for web.Transport Dial field.
+//
+// Deprecated: the deadline set on the connection also kills long downloads,
+// the transports created by this package use ConnectTimeout, ResponseHeaderTimeout and Timeout instead.
 func TimeoutDialer(cTimeout time.Duration, rwTimeout time.Duration) func(net, addr string) (c net.Conn, err error) {
 	return func(netw, addr string) (net.Conn, error) {
 		conn, err := net.DialTimeout(netw, addr, cTimeout)
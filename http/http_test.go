@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Get(ts.URL).WithContext(ctx).Retries(3).Response()
+	if err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected a quick cancellation, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// headers come quickly, the body slowly
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	// a download longer than the read-write timeout is not cut anymore
+	body, err := Get(ts.URL).SetTimeout(time.Second, 30*time.Millisecond).String()
+	if err != nil || body != "xxxxx" {
+		t.Errorf("got %q, %v", body, err)
+	}
+
+	// the total timeout covers the body
+	_, err = Get(ts.URL).SetPhaseTimeouts(0, 0, 50*time.Millisecond).String()
+	if err == nil {
+		t.Error("expected the total timeout to expire while reading the body")
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+)
+
+// Handler sends a request and returns its response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Interceptor wraps the sending of a request, it may change the request,
+// the response or the error, and calls next to continue the chain.
+//
+// example:
+//
+//	func(req *http.Request, next Handler) (*http.Response, error) {
+//		req.Header.Set("Authorization", "Bearer "+token())
+//		return next(req)
+//	}
+type Interceptor func(req *http.Request, next Handler) (*http.Response, error)
+
+// Chain returns a Handler running the interceptors in order around handler,
+// the first interceptor is the outermost one.
+func Chain(handler Handler, interceptors ...Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+	return handler
+}
+
+// DumpInterceptor dumps every request before sending it and passes the dump to fn.
+func DumpInterceptor(dumpBody bool, fn func(dump []byte)) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		dump, err := httputil.DumpRequest(req, dumpBody)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		fn(dump)
+		return next(req)
+	}
+}
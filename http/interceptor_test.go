@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Order")))
+	}))
+	defer ts.Close()
+
+	mark := func(name string) Interceptor {
+		return func(req *http.Request, next Handler) (*http.Response, error) {
+			req.Header.Set("X-Order", req.Header.Get("X-Order")+name)
+			resp, err := next(req)
+			if err == nil {
+				resp.Header.Set("X-After", resp.Header.Get("X-After")+name)
+			}
+			return resp, err
+		}
+	}
+
+	setting := defaultSetting
+	setting.Interceptors = []Interceptor{mark("a")}
+	req := Get(ts.URL).Setting(setting).Intercept(mark("b"), mark("c")).Debug(true)
+	body, err := req.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "abc" {
+		t.Errorf("request order %q, want abc", body)
+	}
+	resp, _ := req.Response()
+	if after := resp.Header.Get("X-After"); after != "cba" {
+		t.Errorf("response order %q, want cba", after)
+	}
+	if !strings.HasPrefix(string(req.DumpRequest()), "GET / HTTP/1.1") {
+		t.Errorf("unexpected dump %q", req.DumpRequest())
+	}
+	if len(setting.Interceptors) != 1 {
+		t.Error("Intercept changed the shared settings")
+	}
+}
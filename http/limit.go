@@ -0,0 +1,324 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ueffort/goutils/logs"
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit of its host is open.
+var ErrCircuitOpen = errors.New("http: circuit breaker is open")
+
+// HostKey groups the requests by host, it is the default key of RateLimiter and CircuitBreaker.
+func HostKey(req *http.Request) string {
+	return req.URL.Host
+}
+
+// RateLimiter limits the requests per key with token buckets,
+// rate tokens are added every second up to burst.
+//
+// example:
+//
+//	limiter, err := http.NewRateLimiter(10, 20)
+//	client := http.NewClient(http.HTTPSettings{Interceptors: []http.Interceptor{limiter.Interceptor()}})
+type RateLimiter struct {
+	rate    float64
+	burst   float64
+	Key     func(req *http.Request) string // nil means HostKey
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+	sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing rate requests per second and bursts of burst requests.
+func NewRateLimiter(rate float64, burst int) (*RateLimiter, error) {
+	if !(rate > 0) {
+		return nil, fmt.Errorf("http: rate limiter rate %g is not positive", rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}, nil
+}
+
+// Allow takes a token of key if one is available.
+func (l *RateLimiter) Allow(key string) bool {
+	return l.reserve(key, false) == 0
+}
+
+// Wait takes a token of key, waiting until one is available or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	wait := l.reserve(key, true)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel(key)
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token of key and returns the time to wait for it,
+// without force no token is taken when one is not available now.
+func (l *RateLimiter) reserve(key string, force bool) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if !force {
+		return -1
+	}
+	// the token is borrowed from the future, the bucket goes negative
+	b.tokens--
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// cancel gives back a token reserved by Wait.
+func (l *RateLimiter) cancel(key string) {
+	l.Lock()
+	defer l.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.tokens++
+	}
+}
+
+// Interceptor returns the interceptor waiting for a token before every request.
+func (l *RateLimiter) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		key := HostKey
+		if l.Key != nil {
+			key = l.Key
+		}
+		if err := l.Wait(req.Context(), key(req)); err != nil {
+			return nil, err
+		}
+		return next(req)
+	}
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	StateClosed   CircuitState = iota // requests are sent
+	StateOpen                         // requests fail with ErrCircuitOpen
+	StateHalfOpen                     // trial requests are sent to check the host recovered
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerSettings configures a CircuitBreaker.
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit, 0 means 5.
+	FailureThreshold int
+	// OpenTimeout is the time the circuit stays open before trying again, 0 means 30s.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of concurrent trial requests when half-open, 0 means 1.
+	HalfOpenRequests int
+	// IsFailure reports whether a request failed, nil means a transport error or a 5xx status.
+	// The requests canceled by the caller are counted neither as failures nor as successes.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called when the circuit of key changes, LogStateChange logs the changes.
+	OnStateChange func(key string, from, to CircuitState)
+	// Key groups the requests sharing a circuit, nil means HostKey.
+	Key func(req *http.Request) string
+}
+
+// CircuitBreaker stops sending requests to a key after consecutive failures,
+// and lets trial requests through once OpenTimeout has passed.
+type CircuitBreaker struct {
+	setting  BreakerSettings
+	circuits map[string]*circuit
+	now      func() time.Time
+	sync.Mutex
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trials   int
+}
+
+// NewCircuitBreaker creates a CircuitBreaker.
+func NewCircuitBreaker(setting BreakerSettings) *CircuitBreaker {
+	if setting.FailureThreshold <= 0 {
+		setting.FailureThreshold = 5
+	}
+	if setting.OpenTimeout <= 0 {
+		setting.OpenTimeout = 30 * time.Second
+	}
+	if setting.HalfOpenRequests <= 0 {
+		setting.HalfOpenRequests = 1
+	}
+	if setting.IsFailure == nil {
+		setting.IsFailure = isFailure
+	}
+	if setting.Key == nil {
+		setting.Key = HostKey
+	}
+	return &CircuitBreaker{
+		setting:  setting,
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+}
+
+// LogStateChange logs the state changes of a circuit breaker through logs.
+func LogStateChange(key string, from, to CircuitState) {
+	if to == StateOpen {
+		logs.Warn("circuit of %s changed from %s to %s", key, from, to)
+	} else {
+		logs.Info("circuit of %s changed from %s to %s", key, from, to)
+	}
+}
+
+func isFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
+
+// State returns the state of the circuit of key.
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.Lock()
+	defer cb.Unlock()
+	c, ok := cb.circuits[key]
+	if !ok {
+		return StateClosed
+	}
+	if c.state == StateOpen && cb.now().Sub(c.openedAt) >= cb.setting.OpenTimeout {
+		return StateHalfOpen
+	}
+	return c.state
+}
+
+// Interceptor returns the interceptor guarding the requests with the breaker.
+func (cb *CircuitBreaker) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		key := cb.setting.Key(req)
+		if err := cb.before(key); err != nil {
+			return nil, err
+		}
+		resp, err := next(req)
+		if errors.Is(err, context.Canceled) {
+			// the caller gave up, the host was neither healthy nor failing
+			cb.release(key)
+			return resp, err
+		}
+		cb.after(key, cb.setting.IsFailure(resp, err))
+		return resp, err
+	}
+}
+
+// before checks that a request of key may be sent.
+func (cb *CircuitBreaker) before(key string) error {
+	cb.Lock()
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[key] = c
+	}
+	var from CircuitState
+	changed := false
+	if c.state == StateOpen {
+		if cb.now().Sub(c.openedAt) < cb.setting.OpenTimeout {
+			cb.Unlock()
+			return ErrCircuitOpen
+		}
+		from, changed = c.state, true
+		c.state, c.trials = StateHalfOpen, 0
+	}
+	if c.state == StateHalfOpen {
+		if c.trials >= cb.setting.HalfOpenRequests {
+			cb.Unlock()
+			cb.notify(key, from, StateHalfOpen, changed)
+			return ErrCircuitOpen
+		}
+		c.trials++
+	}
+	cb.Unlock()
+	cb.notify(key, from, StateHalfOpen, changed)
+	return nil
+}
+
+// after records the result of a request of key.
+func (cb *CircuitBreaker) after(key string, failed bool) {
+	cb.Lock()
+	c := cb.circuits[key]
+	from := c.state
+	switch {
+	case c.state == StateHalfOpen && failed:
+		c.state, c.openedAt = StateOpen, cb.now()
+	case c.state == StateHalfOpen:
+		c.state, c.failures = StateClosed, 0
+	case c.state == StateClosed && failed:
+		c.failures++
+		if c.failures >= cb.setting.FailureThreshold {
+			c.state, c.openedAt = StateOpen, cb.now()
+		}
+	case c.state == StateClosed:
+		c.failures = 0
+	}
+	to := c.state
+	cb.Unlock()
+	cb.notify(key, from, to, from != to)
+}
+
+// release gives back the trial slot of a canceled request of key, leaving the state as it is.
+func (cb *CircuitBreaker) release(key string) {
+	cb.Lock()
+	defer cb.Unlock()
+	if c := cb.circuits[key]; c.state == StateHalfOpen && c.trials > 0 {
+		c.trials--
+	}
+}
+
+func (cb *CircuitBreaker) notify(key string, from, to CircuitState, changed bool) {
+	if changed && cb.setting.OnStateChange != nil {
+		cb.setting.OnStateChange(key, from, to)
+	}
+}
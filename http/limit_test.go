@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	limiter, err := NewRateLimiter(20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(HTTPSettings{Interceptors: []Interceptor{limiter.Interceptor()}})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := client.Get(ts.URL).Bytes(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the burst is free, the next 2 requests wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests took %s", elapsed)
+	}
+	if !limiter.Allow("other") || !limiter.Allow("other") || limiter.Allow("other") {
+		t.Error("the buckets are not per key")
+	}
+
+	for _, rate := range []float64{0, -1} {
+		if _, err := NewRateLimiter(rate, 1); err == nil {
+			t.Errorf("NewRateLimiter(%g) accepted the rate", rate)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var fail int32 = 1
+	var sent int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var changes []CircuitState
+	cb := NewCircuitBreaker(BreakerSettings{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(key string, from, to CircuitState) {
+			mu.Lock()
+			changes = append(changes, to)
+			mu.Unlock()
+		},
+	})
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	client := NewClient(HTTPSettings{Interceptors: []Interceptor{cb.Interceptor()}})
+	key := ts.Listener.Addr().String()
+
+	client.Get(ts.URL).Bytes()
+	client.Get(ts.URL).Bytes()
+	if cb.State(key) != StateOpen {
+		t.Fatalf("state %s after 2 failures", cb.State(key))
+	}
+	if _, err := client.Get(ts.URL).Bytes(); !errors.Is(err, ErrCircuitOpen) || sent != 2 {
+		t.Errorf("open circuit got %v, %d requests sent", err, sent)
+	}
+
+	now = now.Add(time.Minute)
+	atomic.StoreInt32(&fail, 0)
+	if cb.State(key) != StateHalfOpen {
+		t.Errorf("state %s after the timeout", cb.State(key))
+	}
+	if _, err := client.Get(ts.URL).Bytes(); err != nil {
+		t.Fatal(err)
+	}
+	if cb.State(key) != StateClosed {
+		t.Errorf("state %s after a successful trial", cb.State(key))
+	}
+	want := []CircuitState{StateOpen, StateHalfOpen, StateClosed}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] || changes[2] != want[2] {
+		t.Errorf("changes %v, want %v", changes, want)
+	}
+}
+
+func TestCircuitBreakerCanceledTrial(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	client := NewClient(HTTPSettings{Interceptors: []Interceptor{cb.Interceptor()}})
+	key := ts.Listener.Addr().String()
+	client.Get(ts.URL).Bytes()
+	now = now.Add(time.Minute)
+
+	// the trial is canceled, the circuit stays half-open with its slot released
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Get(ts.URL).WithContext(ctx).Bytes(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v", err)
+	}
+	if cb.State(key) != StateHalfOpen {
+		t.Errorf("state %s after a canceled trial", cb.State(key))
+	}
+	if _, err := client.Get(ts.URL).Bytes(); errors.Is(err, ErrCircuitOpen) {
+		t.Error("the trial slot was not released")
+	}
+	if cb.State(key) != StateOpen {
+		t.Errorf("state %s after a failed trial", cb.State(key))
+	}
+}
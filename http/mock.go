@@ -0,0 +1,432 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// ErrNoMock is returned by MockTransport and Recorder for the requests without a match.
+var ErrNoMock = errors.New("http: no mock matches the request")
+
+// TestingT is the part of *testing.T used by the assertions.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// MockTransport is a http.RoundTripper answering the requests with the first matching rule,
+// plugged in with SetTransport or the Transport of HTTPSettings.
+//
+// example:
+//
+//	mock := http.NewMockTransport()
+//	mock.On("GET", "https://api/users/1").Reply(200, `{"id":1}`).ReplyHeader("Content-Type", "application/json")
+//	http.Get("https://api/users/1").SetTransport(mock).ToJSON(&user)
+//	mock.AssertExpectations(t)
+type MockTransport struct {
+	rules     []*MockRule
+	unmatched []string
+	sync.Mutex
+}
+
+// NewMockTransport creates a MockTransport without rules.
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// MockRule matches requests and builds their response.
+type MockRule struct {
+	method  string
+	url     *url.URL
+	header  http.Header
+	body    *string
+	match   func(req *http.Request, body []byte) bool
+	respond func(req *http.Request) (*http.Response, error)
+	status  int
+	resBody []byte
+	resHead http.Header
+	err     error
+	times   int // expected calls, 0 means at least once
+	calls   int
+}
+
+// On adds a rule matching the method and the url, "*" matches every method.
+// An url starting with / matches the path only, the query matches when the
+// request has every param of the rule.
+func (m *MockTransport) On(method, rawurl string) *MockRule {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+	rule := &MockRule{
+		method:  method,
+		url:     u,
+		header:  make(http.Header),
+		status:  http.StatusOK,
+		resHead: make(http.Header),
+	}
+	m.Lock()
+	m.rules = append(m.rules, rule)
+	m.Unlock()
+	return rule
+}
+
+// WithHeader matches the requests having the header with value.
+func (r *MockRule) WithHeader(key, value string) *MockRule {
+	r.header.Add(key, value)
+	return r
+}
+
+// WithBody matches the requests with exactly body.
+func (r *MockRule) WithBody(body string) *MockRule {
+	r.body = &body
+	return r
+}
+
+// Match matches the requests accepted by fn, body is the request body.
+func (r *MockRule) Match(fn func(req *http.Request, body []byte) bool) *MockRule {
+	r.match = fn
+	return r
+}
+
+// Reply answers with status and body.
+func (r *MockRule) Reply(status int, body string) *MockRule {
+	r.status = status
+	r.resBody = []byte(body)
+	return r
+}
+
+// ReplyJSON answers with status and v encoded as JSON.
+func (r *MockRule) ReplyJSON(status int, v interface{}) *MockRule {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.status = status
+	r.resBody = data
+	r.resHead.Set("Content-Type", "application/json")
+	return r
+}
+
+// ReplyHeader adds a header to the response.
+func (r *MockRule) ReplyHeader(key, value string) *MockRule {
+	r.resHead.Add(key, value)
+	return r
+}
+
+// ReplyError fails the request with err.
+func (r *MockRule) ReplyError(err error) *MockRule {
+	r.err = err
+	return r
+}
+
+// Respond builds the responses with fn.
+func (r *MockRule) Respond(fn func(req *http.Request) (*http.Response, error)) *MockRule {
+	r.respond = fn
+	return r
+}
+
+// Times sets the number of expected calls, after which the rule does not match anymore.
+func (r *MockRule) Times(n int) *MockRule {
+	r.times = n
+	return r
+}
+
+func (r *MockRule) matches(req *http.Request, body []byte) bool {
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "*" && r.method != req.Method {
+		return false
+	}
+	if r.url.Scheme != "" && r.url.Scheme != req.URL.Scheme {
+		return false
+	}
+	if r.url.Host != "" && r.url.Host != req.URL.Host {
+		return false
+	}
+	if r.url.Path != req.URL.Path {
+		return false
+	}
+	query := req.URL.Query()
+	for key, values := range r.url.Query() {
+		if !equalValues(query[key], values) {
+			return false
+		}
+	}
+	for key, values := range r.header {
+		if !equalValues(req.Header[key], values) {
+			return false
+		}
+	}
+	if r.body != nil && *r.body != string(body) {
+		return false
+	}
+	return r.match == nil || r.match(req, body)
+}
+
+func (r *MockRule) response(req *http.Request) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.respond != nil {
+		return r.respond(req)
+	}
+	return newResponse(req, r.status, r.resHead.Clone(), r.resBody), nil
+}
+
+func (r *MockRule) String() string {
+	return r.method + " " + r.url.String()
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	m.Lock()
+	var rule *MockRule
+	for _, r := range m.rules {
+		if r.matches(req, body) {
+			rule = r
+			r.calls++
+			break
+		}
+	}
+	if rule == nil {
+		m.unmatched = append(m.unmatched, req.Method+" "+req.URL.String())
+	}
+	m.Unlock()
+	if rule == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoMock, req.Method, req.URL)
+	}
+	// RoundTrip must not modify req, the rule reads the body of a copy
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := rule.response(clone)
+	if resp != nil && resp.Request == clone {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// Calls returns the number of requests matched by rule.
+func (m *MockTransport) Calls(rule *MockRule) int {
+	m.Lock()
+	defer m.Unlock()
+	return rule.calls
+}
+
+// AssertExpectations reports the rules not called the expected number of times
+// and the requests without a match, it returns true when there are none.
+func (m *MockTransport) AssertExpectations(t TestingT) bool {
+	m.Lock()
+	defer m.Unlock()
+	ok := true
+	for _, r := range m.rules {
+		switch {
+		case r.times == 0 && r.calls == 0:
+			t.Errorf("mock %s was not called", r)
+			ok = false
+		case r.times > 0 && r.calls != r.times:
+			t.Errorf("mock %s was called %d times, want %d", r, r.calls, r.times)
+			ok = false
+		}
+	}
+	for _, u := range m.unmatched {
+		t.Errorf("unexpected request %s", u)
+		ok = false
+	}
+	return ok
+}
+
+// RecordMode is the mode of a Recorder.
+type RecordMode int
+
+const (
+	// ModeReplay answers the requests with the exchanges of the fixture file.
+	ModeReplay RecordMode = iota
+	// ModeRecord sends the requests and saves the exchanges to the fixture file.
+	ModeRecord
+)
+
+// Exchange is a request and its response saved in a fixture file.
+type Exchange struct {
+	Method      string
+	URL         string
+	RequestBody []byte `json:",omitempty"`
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+}
+
+// Recorder is a http.RoundTripper recording the exchanges with a real transport
+// to a fixture file, and replaying them in tests without network.
+//
+// example:
+//
+//	rec, err := http.NewRecorder("testdata/users.json", http.ModeReplay, nil)
+//	http.Get(url).SetTransport(rec).ToJSON(&users)
+//	rec.AssertExpectations(t)
+type Recorder struct {
+	fixture   string
+	mode      RecordMode
+	transport http.RoundTripper
+	exchanges []*Exchange
+	used      []bool
+	unmatched []string
+	sync.Mutex
+}
+
+// NewRecorder creates a Recorder on the fixture file, in ModeReplay the file is loaded.
+// In ModeRecord the requests are sent with transport, nil means the default settings.
+func NewRecorder(fixture string, mode RecordMode, transport http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{
+		fixture:   fixture,
+		mode:      mode,
+		transport: transport,
+	}
+	if mode == ModeRecord {
+		if r.transport == nil {
+			r.transport = defaultPool.Transport(defaultSetting)
+		}
+		return r, nil
+	}
+	data, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &r.exchanges); err != nil {
+		return nil, err
+	}
+	r.used = make([]bool, len(r.exchanges))
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	for i, e := range r.exchanges {
+		if !r.used[i] && e.Method == req.Method && e.URL == req.URL.String() && bytes.Equal(e.RequestBody, body) {
+			r.used[i] = true
+			return newResponse(req, e.StatusCode, e.Header.Clone(), e.Body), nil
+		}
+	}
+	r.unmatched = append(r.unmatched, req.Method+" "+req.URL.String())
+	return nil, fmt.Errorf("%w: %s %s", ErrNoMock, req.Method, req.URL)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := r.transport.RoundTrip(clone)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	resBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	header := resp.Header.Clone()
+	// the fixtures must not keep the credentials
+	header.Del("Set-Cookie")
+	r.Lock()
+	r.exchanges = append(r.exchanges, &Exchange{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: body,
+		StatusCode:  resp.StatusCode,
+		Header:      header,
+		Body:        resBody,
+	})
+	r.Unlock()
+	return resp, nil
+}
+
+// Save writes the recorded exchanges to the fixture file.
+func (r *Recorder) Save() error {
+	r.Lock()
+	data, err := json.MarshalIndent(r.exchanges, "", "  ")
+	r.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.fixture, data, 0644)
+}
+
+// AssertExpectations reports, in ModeReplay, the exchanges not replayed and the
+// requests without a match, it returns true when there are none.
+func (r *Recorder) AssertExpectations(t TestingT) bool {
+	r.Lock()
+	defer r.Unlock()
+	ok := true
+	for i, e := range r.exchanges {
+		if r.mode == ModeReplay && !r.used[i] {
+			t.Errorf("exchange %s %s was not replayed", e.Method, e.URL)
+			ok = false
+		}
+	}
+	for _, u := range r.unmatched {
+		t.Errorf("unexpected request %s", u)
+		ok = false
+	}
+	return ok
+}
+
+// readRequestBody reads and closes the body of req.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return ioutil.ReadAll(req.Body)
+}
+
+// newResponse builds a response of req with body.
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordT records the failures of the assertions.
+type recordT struct {
+	errors []string
+}
+
+func (t *recordT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockTransport(t *testing.T) {
+	mock := NewMockTransport()
+	mock.On("GET", "http://api/users").WithHeader("X-Token", "t").ReplyJSON(200, []string{"a", "b"})
+	mock.On("POST", "/users").WithBody("name=c").Reply(201, "created").Times(1)
+	mock.On("GET", "/users?page=2").Reply(200, "[]")
+	mock.On("GET", "/down").ReplyError(errors.New("boom"))
+	unused := mock.On("DELETE", "/users")
+
+	var users []string
+	if err := Get("http://api/users").Header("X-Token", "t").SetTransport(mock).ToJSON(&users); err != nil || len(users) != 2 {
+		t.Errorf("got %v, %v", users, err)
+	}
+	if body, err := Post("http://api/users").Param("name", "c").SetTransport(mock).String(); err != nil || body != "created" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if body, _ := Get("http://api/users").Param("page", "2").SetTransport(mock).String(); body != "[]" {
+		t.Errorf("query rule got %q", body)
+	}
+	if _, err := Get("http://api/down").SetTransport(mock).Bytes(); err == nil {
+		t.Error("expected the error of the rule")
+	}
+	// Times(1) is used up
+	if _, err := Post("http://api/users").Param("name", "c").SetTransport(mock).Bytes(); !errors.Is(err, ErrNoMock) {
+		t.Errorf("got %v, want ErrNoMock", err)
+	}
+
+	rt := &recordT{}
+	if mock.AssertExpectations(rt) || len(rt.errors) != 2 {
+		t.Errorf("unexpected assertion failures %v", rt.errors)
+	}
+	if mock.Calls(unused) != 0 {
+		t.Error("the unused rule was called")
+	}
+
+	// RoundTrip leaves the request as it was
+	echo := mock.On("PUT", "/echo").Respond(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		return newResponse(req, 200, nil, body), nil
+	})
+	req, _ := http.NewRequest("PUT", "http://api/echo", strings.NewReader("data"))
+	reqBody := req.Body
+	resp, err := mock.RoundTrip(req)
+	if err != nil || mock.Calls(echo) != 1 {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(resp.Body); string(data) != "data" || req.Body != reqBody || resp.Request != req {
+		t.Errorf("got %q, the request was modified", data)
+	}
+
+	old := defaultSetting
+	defer SetDefaultSetting(old)
+	setting := old
+	setting.Transport = mock
+	SetDefaultSetting(setting)
+	if body, _ := Get("http://api/users").Param("page", "2").String(); body != "[]" {
+		t.Errorf("default settings transport got %q", body)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	}))
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+
+	rec, err := NewRecorder(fixture, ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := Get(ts.URL).Param("name", name).SetTransport(rec).Bytes(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// recording leaves the request as it was
+	raw, _ := http.NewRequest("POST", ts.URL, strings.NewReader("data"))
+	rawBody := raw.Body
+	if resp, err := rec.RoundTrip(raw); err != nil || raw.Body != rawBody || resp.Request != raw {
+		t.Errorf("got %v, the request was modified", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	url := ts.URL
+	ts.Close()
+
+	replay, err := NewRecorder(fixture, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Get(url).Param("name", "b").SetTransport(replay)
+	if body, err := req.String(); err != nil || body != "hello b" || req.res.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("replay got %q, %v", body, err)
+	}
+	rt := &recordT{}
+	if replay.AssertExpectations(rt) || len(rt.errors) != 2 {
+		t.Errorf("unexpected assertion failures %v", rt.errors)
+	}
+	if _, err := Get(url).Param("name", "c").SetTransport(replay).Bytes(); !errors.Is(err, ErrNoMock) {
+		t.Errorf("got %v, want ErrNoMock", err)
+	}
+}
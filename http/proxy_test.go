@@ -0,0 +1,244 @@
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connectProxy is a test HTTP proxy requiring basic auth, it forwards plain requests and tunnels CONNECT.
+func connectProxy(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if r.Method != "CONNECT" {
+			r.RequestURI = ""
+			r.Header.Del("Proxy-Authorization")
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.Header().Set("Via", "test-proxy")
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		pipe(conn, target)
+	}))
+}
+
+// socks5Proxy is a test SOCKS5 server requiring the user/password method.
+func socks5Proxy(t *testing.T, hits *int32) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(hits, 1)
+			go serveSocks5(conn)
+		}
+	}()
+	return l
+}
+
+func serveSocks5(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil || head[0] != 5 {
+		conn.Close()
+		return
+	}
+	methods := make([]byte, head[1])
+	io.ReadFull(r, methods)
+	conn.Write([]byte{5, 2})
+	// RFC 1929
+	io.ReadFull(r, head[:])
+	user := make([]byte, head[1])
+	io.ReadFull(r, user)
+	plen, _ := r.ReadByte()
+	pass := make([]byte, plen)
+	io.ReadFull(r, pass)
+	if string(user) != "user" || string(pass) != "pass" {
+		conn.Write([]byte{1, 1})
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	var req [4]byte
+	io.ReadFull(r, req[:])
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(r, ip)
+		host = net.IP(ip).String()
+	case 3:
+		n, _ := r.ReadByte()
+		name := make([]byte, n)
+		io.ReadFull(r, name)
+		host = string(name)
+	}
+	var port [2]byte
+	io.ReadFull(r, port[:])
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(&bufferedConn{conn, r}, target)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+func TestProxyRules(t *testing.T) {
+	rules, err := NewProxyRules("http://proxy:3128", "localhost, .internal,example.com:8080,10.0.0.0/8,[::1],secure.example:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"localhost":            true,
+		"localhost:80":         true,
+		"a.internal":           true,
+		"internal":             false,
+		"example.com:8080":     true,
+		"example.com":          false,
+		"www.example.com:8080": true,
+		"10.1.2.3:443":         true,
+		"11.1.2.3":             false,
+		"[::1]:80":             true,
+		"golang.org":           false,
+	}
+	for host, bypass := range cases {
+		if rules.Bypass(host) != bypass {
+			t.Errorf("Bypass(%s) = %t", host, !bypass)
+		}
+	}
+	for target, bypass := range map[string]bool{
+		"https://secure.example/":     true,
+		"http://secure.example/":      false,
+		"http://secure.example:443/":  true,
+		"https://secure.example:8443": false,
+		"http://example.com:8080/":    true,
+	} {
+		req, _ := http.NewRequest("GET", target, nil)
+		if proxy, err := rules.Proxy()(req); err != nil || (proxy == nil) != bypass {
+			t.Errorf("Proxy(%s) = %v, %v", target, proxy, err)
+		}
+	}
+	if _, err := NewProxyRules("ftp://proxy", ""); err == nil {
+		t.Error("expected an error for an ftp proxy")
+	}
+}
+
+func TestConnectProxy(t *testing.T) {
+	var hits int32
+	proxy := connectProxy(&hits)
+	defer proxy.Close()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer ts.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	var dials int32
+	dial := func(network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return TimeoutDialer(time.Second, 10*time.Second)(network, addr)
+	}
+	rules, _ := NewProxyRules("http://user:pass@"+proxy.Listener.Addr().String(), "")
+	client := NewClient(HTTPSettings{
+		Proxy:           rules.Proxy(),
+		DialContext:     DialFunc(dial),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	})
+	if body, err := client.Get(ts.URL).String(); err != nil || body != "secure" {
+		t.Errorf("CONNECT got %q, %v", body, err)
+	}
+	req := client.Get(plain.URL)
+	if body, err := req.String(); err != nil || body != "plain" || req.res.Header.Get("Via") != "test-proxy" {
+		t.Errorf("forward got %q, %v", body, err)
+	}
+	if atomic.LoadInt32(&hits) != 2 || atomic.LoadInt32(&dials) != 2 {
+		t.Errorf("%d proxy hits, %d dials", atomic.LoadInt32(&hits), atomic.LoadInt32(&dials))
+	}
+
+	bad, _ := NewProxyRules("http://user:wrong@"+proxy.Listener.Addr().String(), "")
+	if _, err := Get(ts.URL).SetProxyRules(bad).SetTLSClientConfig(&tls.Config{RootCAs: roots}).String(); err == nil {
+		t.Error("expected an error with wrong proxy credentials")
+	}
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	var hits int32
+	l := socks5Proxy(t, &hits)
+	defer l.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	rules, _ := NewProxyRules("socks5://user:pass@"+l.Addr().String(), "")
+	if body, err := Get(ts.URL).SetProxyRules(rules).String(); err != nil || body != "ok" || atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("got %q, %v with %d hits", body, err, atomic.LoadInt32(&hits))
+	}
+	// the target is in NO_PROXY
+	direct, _ := NewProxyRules("socks5://user:pass@"+l.Addr().String(), "127.0.0.1")
+	if body, err := Get(ts.URL).SetProxyRules(direct).String(); err != nil || body != "ok" || atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("bypass got %q, %v with %d hits", body, err, atomic.LoadInt32(&hits))
+	}
+	wrong, _ := NewProxyRules("socks5://user:wrong@"+l.Addr().String(), "")
+	if _, err := Get(ts.URL).SetProxyRules(wrong).String(); err == nil {
+		t.Error("expected an error with wrong socks credentials")
+	}
+}
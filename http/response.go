@@ -0,0 +1,184 @@
+package http
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxErrorBody is the number of body bytes kept in an HTTPError.
+var MaxErrorBody = 4096
+
+// StatusMatcher reports whether a status code is expected.
+type StatusMatcher func(code int) bool
+
+// Status2xx matches the success status codes.
+var Status2xx = StatusRange(200, 299)
+
+// StatusRange matches the status codes from min to max inclusive.
+func StatusRange(min, max int) StatusMatcher {
+	return func(code int) bool {
+		return code >= min && code <= max
+	}
+}
+
+// StatusIn matches the listed status codes.
+func StatusIn(codes ...int) StatusMatcher {
+	return func(code int) bool {
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HTTPError is returned when the status code of a response is not expected.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte // at most MaxErrorBody bytes
+	Detail     interface{}
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("http: %s %s: %s", e.Method, e.URL, e.Status)
+	if len(e.Body) > 0 {
+		body := e.Body
+		if len(body) > 200 {
+			body = body[:200]
+		}
+		msg += ": " + string(body)
+	}
+	return msg
+}
+
+// ErrorDecoder turns an unexpected response into the error returned to the caller,
+// it may decode e.Body into e.Detail or return an error type of its own.
+type ErrorDecoder func(e *HTTPError) error
+
+// JSONErrorDecoder decodes the error body as JSON into a value created by newDetail
+// and stores it in HTTPError.Detail.
+func JSONErrorDecoder(newDetail func() interface{}) ErrorDecoder {
+	return func(e *HTTPError) error {
+		detail := newDetail()
+		if err := json.Unmarshal(e.Body, detail); err == nil {
+			e.Detail = detail
+		}
+		return e
+	}
+}
+
+// Expect sets the expected status codes, the body readers return an *HTTPError
+// for other codes. Without Expect, only ToJSON, ToXML and Decode check for Status2xx.
+func (b *Context) Expect(matcher StatusMatcher) *Context {
+	b.setting.ExpectStatus = matcher
+	return b
+}
+
+// SetErrorDecoder sets the decoder of the unexpected responses.
+func (b *Context) SetErrorDecoder(decoder ErrorDecoder) *Context {
+	b.setting.ErrorDecoder = decoder
+	return b
+}
+
+// statusError builds the error of an unexpected response with its body.
+func (b *Context) statusError(resp *http.Response, body []byte) error {
+	if len(body) > MaxErrorBody {
+		body = body[:MaxErrorBody]
+	}
+	e := &HTTPError{
+		Method:     b.req.Method,
+		URL:        b.req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if b.setting.ErrorDecoder != nil {
+		return b.setting.ErrorDecoder(e)
+	}
+	return e
+}
+
+// checkStream returns the error of an unexpected response, reading and closing its body.
+func (b *Context) checkStream(resp *http.Response, body io.ReadCloser) error {
+	if b.setting.ExpectStatus == nil || b.setting.ExpectStatus(resp.StatusCode) {
+		return nil
+	}
+	var data []byte
+	if body != nil {
+		data, _ = ioutil.ReadAll(io.LimitReader(body, int64(MaxErrorBody)))
+		body.Close()
+	}
+	return b.statusError(resp, data)
+}
+
+// expectedBytes returns the body if the status matches the expected one, or m without Expect.
+func (b *Context) expectedBytes(m StatusMatcher) ([]byte, error) {
+	data, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if b.setting.ExpectStatus == nil && !m(b.res.StatusCode) {
+		return nil, b.statusError(b.res, data)
+	}
+	return data, nil
+}
+
+// Decode decodes the body according to the Content-Type of the response:
+// JSON, XML, url-encoded forms into *url.Values or *map[string][]string, and
+// binary protobuf-like messages into values implementing Unmarshal([]byte) error
+// or encoding.BinaryUnmarshaler.
+// it calls Response inner.
+func (b *Context) Decode(v interface{}) error {
+	data, err := b.expectedBytes(Status2xx)
+	if err != nil {
+		return err
+	}
+	ct, _, _ := mime.ParseMediaType(b.res.Header.Get("Content-Type"))
+	return decodeBody(ct, data, v)
+}
+
+func decodeBody(contentType string, data []byte, v interface{}) error {
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		return json.Unmarshal(data, v)
+	case contentType == "application/xml" || contentType == "text/xml" || strings.HasSuffix(contentType, "+xml"):
+		return xml.Unmarshal(data, v)
+	case contentType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return err
+		}
+		switch t := v.(type) {
+		case *url.Values:
+			*t = values
+		case *map[string][]string:
+			*t = values
+		default:
+			return fmt.Errorf("http: can not decode a form into %T", v)
+		}
+		return nil
+	case contentType == "application/x-protobuf" || contentType == "application/protobuf" || contentType == "application/octet-stream":
+		switch t := v.(type) {
+		case interface{ Unmarshal([]byte) error }:
+			return t.Unmarshal(data)
+		case encoding.BinaryUnmarshaler:
+			return t.UnmarshalBinary(data)
+		}
+		return fmt.Errorf("http: can not decode %s into %T", contentType, v)
+	}
+	return fmt.Errorf("http: unsupported content type %q", contentType)
+}
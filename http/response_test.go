@@ -0,0 +1,127 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "42")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code":"internal","message":"boom"}` + strings.Repeat(" ", 2*MaxErrorBody)))
+	}))
+	defer ts.Close()
+
+	// without Expect only the decoders check the status
+	if body, err := Get(ts.URL).String(); err != nil || !strings.HasPrefix(body, `{"code"`) {
+		t.Errorf("String got %q, %v", body, err)
+	}
+	var v map[string]interface{}
+	err := Get(ts.URL).ToJSON(&v)
+	var herr *HTTPError
+	if !errors.As(err, &herr) {
+		t.Fatalf("ToJSON got %v, want an *HTTPError", err)
+	}
+	if herr.StatusCode != 500 || herr.Header.Get("X-Request-Id") != "42" || len(herr.Body) != MaxErrorBody {
+		t.Errorf("unexpected error %d %v %d", herr.StatusCode, herr.Header, len(herr.Body))
+	}
+	if v != nil {
+		t.Errorf("the error page was decoded: %v", v)
+	}
+
+	if _, err := Get(ts.URL).Expect(Status2xx).Bytes(); !errors.As(err, &herr) {
+		t.Errorf("Bytes with Expect got %v", err)
+	}
+	if _, err := Get(ts.URL).Expect(StatusIn(200, 500)).Bytes(); err != nil {
+		t.Errorf("Bytes with an expected 500 got %v", err)
+	}
+
+	type apiError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	err = Get(ts.URL).Expect(Status2xx).
+		SetErrorDecoder(JSONErrorDecoder(func() interface{} { return &apiError{} })).
+		ToJSON(&v)
+	if !errors.As(err, &herr) {
+		t.Fatalf("got %v", err)
+	}
+	if detail, ok := herr.Detail.(*apiError); !ok || detail.Code != "internal" {
+		t.Errorf("unexpected detail %#v", herr.Detail)
+	}
+
+	// a failed download leaves the file alone
+	name := filepath.Join(t.TempDir(), "out")
+	ioutil.WriteFile(name, []byte("keep"), 0666)
+	if err := Get(ts.URL).Expect(Status2xx).ToFile(name); err == nil {
+		t.Error("ToFile expected an error")
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != "keep" {
+		t.Errorf("the file was overwritten with %q", data)
+	}
+	os.Remove(name)
+}
+
+type binaryMessage struct {
+	data []byte
+}
+
+func (m *binaryMessage) Unmarshal(data []byte) error {
+	m.data = append([]byte(nil), data...)
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			w.Write([]byte(`{"name":"a"}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(`<item><name>b</name></item>`))
+		case "/form":
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte(`name=c&name=d`))
+		case "/proto":
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.Write([]byte{0x0a, 0x01, 'e'})
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("f"))
+		}
+	}))
+	defer ts.Close()
+
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	var j, x item
+	if err := Get(ts.URL + "/json").Decode(&j); err != nil || j.Name != "a" {
+		t.Errorf("json got %v, %v", j, err)
+	}
+	if err := Get(ts.URL + "/xml").Decode(&x); err != nil || x.Name != "b" {
+		t.Errorf("xml got %v, %v", x, err)
+	}
+	var form url.Values
+	if err := Get(ts.URL + "/form").Decode(&form); err != nil || len(form["name"]) != 2 {
+		t.Errorf("form got %v, %v", form, err)
+	}
+	var msg binaryMessage
+	if err := Get(ts.URL + "/proto").Decode(&msg); err != nil || !bytes.Equal(msg.data, []byte{0x0a, 0x01, 'e'}) {
+		t.Errorf("proto got %v, %v", msg.data, err)
+	}
+	if err := Get(ts.URL + "/text").Decode(&j); err == nil {
+		t.Error("expected an error for text/plain")
+	}
+}
@@ -0,0 +1,220 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ueffort/goutils/logs"
+)
+
+// RetryPolicy decides whether a request is sent again.
+// attempt is the number of the retry that would happen, starting at 1,
+// and elapsed the time since the first attempt was sent.
+type RetryPolicy interface {
+	Retry(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (wait time.Duration, retry bool)
+}
+
+// ErrNotReplayable is matched by the errors of the requests a RetryPolicy would retry
+// but whose body, like a streamed reader or a multipart form, cannot be sent again.
+var ErrNotReplayable = errors.New("http: not retried, the request body is not replayable")
+
+// IdempotentMethods are the methods BackoffPolicy retries when Methods is nil.
+var IdempotentMethods = []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE"}
+
+// RetryStatuses are the status codes BackoffPolicy retries when Statuses is nil.
+var RetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// BackoffPolicy retries transport errors and retryable status codes with an
+// exponential backoff: MinDelay, MinDelay*Multiplier, ... up to MaxDelay.
+type BackoffPolicy struct {
+	MaxRetries int // -1 means retry until MaxElapsed
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+	Multiplier float64
+	// Jitter randomizes every delay by ±Jitter of its value, between 0 and 1.
+	Jitter float64
+	// MaxElapsed caps the time spent since the first attempt, 0 means no cap.
+	MaxElapsed time.Duration
+	// Statuses are the retried status codes, nil means RetryStatuses.
+	Statuses []int
+	// Methods are the retried methods, nil means IdempotentMethods and "*" matches every method.
+	Methods []string
+	// IgnoreRetryAfter disables waiting for the Retry-After header of 429 and 503 responses.
+	IgnoreRetryAfter bool
+}
+
+// NewRetryPolicy returns a BackoffPolicy retrying idempotent requests maxRetries
+// times, waiting from 100ms up to 10s with 20% jitter.
+func NewRetryPolicy(maxRetries int) *BackoffPolicy {
+	return &BackoffPolicy{
+		MaxRetries: maxRetries,
+		MinDelay:   100 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// Retry implements RetryPolicy.
+func (p *BackoffPolicy) Retry(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if p.MaxRetries >= 0 && attempt > p.MaxRetries {
+		return 0, false
+	}
+	if !p.retryMethod(req.Method) || errors.Is(err, ErrCircuitOpen) {
+		return 0, false
+	}
+	if err == nil && !p.retryStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	wait := p.backoff(attempt)
+	if err == nil && !p.IgnoreRetryAfter {
+		if after, ok := retryAfter(resp); ok {
+			wait = after
+		}
+	}
+	if p.MaxElapsed > 0 && elapsed+wait > p.MaxElapsed {
+		return 0, false
+	}
+	return wait, true
+}
+
+func (p *BackoffPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	wait := float64(p.MinDelay)
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || wait < float64(p.MaxDelay)); i++ {
+		wait *= multiplier
+	}
+	if p.MaxDelay > 0 && wait > float64(p.MaxDelay) {
+		wait = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+func (p *BackoffPolicy) retryMethod(method string) bool {
+	methods := p.Methods
+	if methods == nil {
+		methods = IdempotentMethods
+	}
+	for _, m := range methods {
+		if m == method || m == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *BackoffPolicy) retryStatus(status int) bool {
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = RetryStatuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, in seconds or as an http date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// replayable reports whether the body of req can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// notReplayableError is the error of a request not retried because of its body.
+type notReplayableError struct {
+	err error
+}
+
+func (e *notReplayableError) Error() string {
+	return ErrNotReplayable.Error() + ": " + e.err.Error()
+}
+
+func (e *notReplayableError) Unwrap() error {
+	return e.err
+}
+
+func (e *notReplayableError) Is(target error) bool {
+	return target == ErrNotReplayable
+}
+
+// doRetry sends req through handler until policy gives up, rewinding the body before every retry.
+func doRetry(req *http.Request, handler Handler, policy RetryPolicy) (resp *http.Response, err error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err = handler(req)
+		if policy == nil || req.Context().Err() != nil {
+			return
+		}
+		wait, retry := policy.Retry(req, resp, err, attempt, time.Since(start))
+		if !retry {
+			return
+		}
+		if !replayable(req) {
+			if err != nil {
+				return resp, &notReplayableError{err}
+			}
+			logs.Warn("http %s %s %d not retried: the request body is not replayable", req.Method, req.URL.Redacted(), resp.StatusCode)
+			return
+		}
+		if req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return
+			}
+			req.Body = body
+		}
+		if resp != nil && resp.Body != nil {
+			// drain the body so the connection can be reused
+			io.CopyN(ioutil.Discard, resp.Body, 4096)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryReplaysBody(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d got body %q", calls, body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	policy := NewRetryPolicy(3)
+	policy.Methods = []string{"POST"}
+	policy.MinDelay = time.Millisecond
+	body, err := Post(ts.URL).Body("payload").SetRetryPolicy(policy).String()
+	if err != nil || body != "ok" {
+		t.Fatalf("got %q, %v", body, err)
+	}
+	if calls != 3 {
+		t.Errorf("server called %d times, want 3", calls)
+	}
+}
+
+func TestRetryRules(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	policy := NewRetryPolicy(5)
+	policy.MinDelay = time.Millisecond
+
+	// POST is not idempotent
+	resp, err := Post(ts.URL).Body("x").SetRetryPolicy(policy).Response()
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || calls != 1 {
+		t.Fatalf("POST: status %v, err %v, calls %d", resp, err, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	resp, err = Get(ts.URL).SetRetryPolicy(policy).Response()
+	if err != nil || calls != 6 {
+		t.Fatalf("GET: err %v, calls %d, want 6", err, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	policy.MaxRetries = -1
+	policy.MinDelay = 10 * time.Millisecond
+	policy.MaxElapsed = 50 * time.Millisecond
+	start := time.Now()
+	Get(ts.URL).SetRetryPolicy(policy).Response()
+	if d := time.Since(start); d > 500*time.Millisecond || calls < 2 {
+		t.Errorf("MaxElapsed: took %s with %d calls", d, calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "2")
+	if d, ok := retryAfter(resp); !ok || d != 2*time.Second {
+		t.Errorf("seconds: %s, %v", d, ok)
+	}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if d, ok := retryAfter(resp); !ok || d < 59*time.Minute {
+		t.Errorf("date: %s, %v", d, ok)
+	}
+	policy := &BackoffPolicy{MaxRetries: 1, MaxElapsed: time.Minute}
+	if _, retry := policy.Retry(&http.Request{Method: "GET"}, resp, nil, 1, 0); retry {
+		t.Error("Retry-After beyond MaxElapsed should give up")
+	}
+}
+
+func TestRetryNotReplayable(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// a transport error
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	policy := NewRetryPolicy(3)
+	policy.Methods = []string{"POST"}
+	policy.MinDelay = time.Millisecond
+	// a reader hiding its Seek method
+	resp, err := Post(ts.URL).Body(struct{ io.Reader }{strings.NewReader("payload")}).SetRetryPolicy(policy).Response()
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, %v", resp, err)
+	}
+	resp.Body.Close()
+	_, err = Post(ts.URL).Body(struct{ io.Reader }{strings.NewReader("payload")}).SetRetryPolicy(policy).Response()
+	if !errors.Is(err, ErrNotReplayable) {
+		t.Errorf("got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("server called %d times, want 2", n)
+	}
+}
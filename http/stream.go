@@ -0,0 +1,227 @@
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"strings"
+)
+
+// ProgressFunc is called while a body is transferred with the bytes done so far
+// and the total, -1 if the total is unknown.
+type ProgressFunc func(current, total int64)
+
+// filePart is a multipart file of the request, read from reader or from the file filename.
+type filePart struct {
+	formname string
+	filename string
+	reader   io.Reader
+}
+
+// UploadProgress sets a callback following the sending of the request body.
+func (b *Context) UploadProgress(fn ProgressFunc) *Context {
+	b.uploadProgress = fn
+	return b
+}
+
+// DownloadProgress sets a callback following the reading of the response body.
+func (b *Context) DownloadProgress(fn ProgressFunc) *Context {
+	b.downloadProgress = fn
+	return b
+}
+
+// Resume makes ToFile continue an existing file with a Range request
+// instead of downloading it again.
+func (b *Context) Resume(enable bool) *Context {
+	b.resume = enable
+	return b
+}
+
+// Checksum makes ToFile verify the downloaded file, sum is the expected hex digest of h.
+//
+// example:
+//
+//	http.Get(url).Checksum(sha256.New(), "9f86d081884c7d659a2feaa0c55ad015...").ToFile(name)
+func (b *Context) Checksum(h hash.Hash, sum string) *Context {
+	b.checksum = h
+	b.checksumSum = strings.ToLower(sum)
+	return b
+}
+
+// Stream returns the body of the response to be read by the caller, who must close it.
+// Unlike Bytes it does not buffer the body in memory.
+// it calls Response inner.
+func (b *Context) Stream() (io.ReadCloser, error) {
+	return b.stream(0)
+}
+
+// ToWriter copies the body of the response to w without buffering it.
+// it calls Response inner.
+func (b *Context) ToWriter(w io.Writer) error {
+	body, err := b.Stream()
+	if err != nil || body == nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// stream returns the decoded body, reporting the download progress from offset.
+func (b *Context) stream(offset int64) (io.ReadCloser, error) {
+	resp, err := b.getResponse()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body == nil {
+		return nil, b.checkStream(resp, nil)
+	}
+	var body io.ReadCloser = resp.Body
+	if b.downloadProgress != nil {
+		total := resp.ContentLength
+		if total >= 0 {
+			total += offset
+		}
+		body = &progressReader{body, offset, total, b.downloadProgress}
+	}
+	if err = b.checkStream(resp, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// setBodyReader streams r as the body, the caller keeps the ownership of r.
+func (b *Context) setBodyReader(r io.Reader) {
+	b.req.ContentLength = -1
+	switch v := r.(type) {
+	case *bytes.Reader:
+		b.req.ContentLength = int64(v.Len())
+	case *bytes.Buffer:
+		b.req.ContentLength = int64(v.Len())
+	case *strings.Reader:
+		b.req.ContentLength = int64(v.Len())
+	case *os.File:
+		if info, err := v.Stat(); err == nil && info.Mode().IsRegular() {
+			if pos, err := v.Seek(0, io.SeekCurrent); err == nil {
+				b.req.ContentLength = info.Size() - pos
+			}
+		}
+	}
+	b.req.Body = ioutil.NopCloser(r)
+	b.req.GetBody = nil
+	if seeker, ok := r.(io.Seeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			b.req.GetBody = func() (io.ReadCloser, error) {
+				if _, err := seeker.Seek(pos, io.SeekStart); err != nil {
+					return nil, err
+				}
+				return ioutil.NopCloser(r), nil
+			}
+		}
+	}
+}
+
+// wrapUpload reports the progress of sending the request body.
+func (b *Context) wrapUpload() {
+	if b.uploadProgress == nil || b.req.Body == nil {
+		return
+	}
+	total := b.req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+	b.req.Body = &progressReader{b.req.Body, 0, total, b.uploadProgress}
+	if getBody := b.req.GetBody; getBody != nil {
+		b.req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressReader{body, 0, total, b.uploadProgress}, nil
+		}
+	}
+}
+
+// writeMultipart writes the files and the params of the request as multipart form.
+func (b *Context) writeMultipart(w *multipart.Writer) error {
+	defer b.closeFiles()
+	for _, part := range b.files {
+		fileWriter, err := w.CreateFormFile(part.formname, part.filename)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(fileWriter, part.reader); err != nil {
+			return err
+		}
+	}
+	for _, p := range b.params {
+		if err := w.WriteField(p.key, p.value); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (b *Context) closeFiles() {
+	for _, part := range b.files {
+		if c, ok := part.reader.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// verifyFile compares the checksum of filename with the expected sum.
+// h holds the hash of the whole file already, if nil the file is read again.
+func (b *Context) verifyFile(filename string, h hash.Hash) error {
+	if b.checksum == nil {
+		return nil
+	}
+	if h == nil {
+		h = b.checksum
+		h.Reset()
+		if err := hashFile(h, filename, -1); err != nil {
+			return err
+		}
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != b.checksumSum {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", filename, sum, b.checksumSum)
+	}
+	return nil
+}
+
+// hashFile writes the first n bytes of filename into h, the whole file if n < 0.
+func hashFile(h hash.Hash, filename string, n int64) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if n < 0 {
+		_, err = io.Copy(h, f)
+	} else {
+		_, err = io.CopyN(h, f, n)
+	}
+	return err
+}
+
+// progressReader calls fn after every read.
+type progressReader struct {
+	io.ReadCloser
+	current int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.current += int64(n)
+		p.fn(p.current, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostFileMissing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, err := Post(ts.URL).PostFile("f", filepath.Join(t.TempDir(), "missing")).Response()
+	if !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestPostFileFailed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "upload")
+	if err := ioutil.WriteFile(filename, bytes.Repeat([]byte("x"), 1<<20), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+	refuse := func(req *http.Request, next Handler) (*http.Response, error) {
+		return nil, errors.New("refused")
+	}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if _, err := Post(ts.URL).PostFile("f", filename).Response(); err == nil {
+			t.Fatal("expected an error from a closed server")
+		}
+		if _, err := Post(ts.URL).PostFile("f", filename).Intercept(refuse).Response(); err == nil {
+			t.Fatal("expected an error from the interceptor")
+		}
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left by the failed uploads", n-before)
+	}
+}
+
+func TestPostReader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("doc")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		w.Write([]byte(h.Filename + ":" + string(data) + ":" + r.FormValue("k")))
+	}))
+	defer ts.Close()
+
+	var uploaded int64
+	body, err := Post(ts.URL).
+		PostReader("doc", "a.txt", strings.NewReader("content")).
+		Param("k", "v").
+		UploadProgress(func(current, total int64) { uploaded = current }).
+		String()
+	if err != nil || body != "a.txt:content:v" {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if uploaded == 0 {
+		t.Error("upload progress not reported")
+	}
+}
+
+func TestBodyReaderRetry(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	policy := NewRetryPolicy(1)
+	policy.Methods = []string{"*"}
+	policy.MinDelay = time.Millisecond
+	body, err := Post(ts.URL).Body(bytes.NewReader([]byte("stream"))).SetRetryPolicy(policy).String()
+	if err != nil || body != "stream" || calls != 2 {
+		t.Errorf("got %q, %v after %d calls", body, err, calls)
+	}
+}
+
+func TestToFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	ranges := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256(content)
+	filename := filepath.Join(t.TempDir(), "data")
+	if err := ioutil.WriteFile(filename, content[:4000], 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var last, total int64
+	err := Get(ts.URL).Resume(true).
+		Checksum(sha256.New(), hex.EncodeToString(sum[:])).
+		DownloadProgress(func(current, t int64) { last, total = current, t }).
+		ToFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filename)
+	if !bytes.Equal(data, content) || ranges != 1 {
+		t.Errorf("resumed file has %d bytes after %d range requests", len(data), ranges)
+	}
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress %d/%d", last, total)
+	}
+
+	// the file is complete, only the checksum is checked
+	if err := Get(ts.URL).Resume(true).Checksum(sha256.New(), hex.EncodeToString(sum[:])).ToFile(filename); err != nil {
+		t.Error(err)
+	}
+	if err := Get(ts.URL).Checksum(sha256.New(), "00").ToFile(filename); err == nil {
+		t.Error("expected a checksum mismatch")
+	}
+}
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// param is a query or form parameter, kept in the order it was added.
+type param struct {
+	key   string
+	value string
+}
+
+// Template is an immutable description of a request. Every setter returns a
+// modified copy, so a Template can be shared, derived from and executed by
+// many goroutines at once. Each execution builds a new single-shot Context.
+//
+// example:
+//
+//	user := http.NewTemplate("GET", "https://api.example.com/users/{id}").Header("Accept", "application/json")
+//	err := user.PathParam("id", "42").Param("fields", "name").ToJSON(&u)
+type Template struct {
+	method     string
+	rawurl     string
+	header     http.Header
+	params     []param
+	pathParams map[string]string
+	body       []byte
+	setting    HTTPSettings
+	pool       *TransportPool
+}
+
+// NewTemplate creates a Template with the default settings.
+// rawurl may contain {name} placeholders replaced by PathParam values.
+func NewTemplate(method, rawurl string) *Template {
+	return &Template{
+		method:  method,
+		rawurl:  rawurl,
+		header:  make(http.Header),
+		setting: defaultSetting,
+		pool:    defaultPool,
+	}
+}
+
+// Template creates a Template using the settings and the transports of the client.
+func (c *Client) Template(method, rawurl string) *Template {
+	t := NewTemplate(method, rawurl)
+	t.setting = c.setting
+	t.pool = c.pool
+	return t
+}
+
+// Clone returns a copy of the template.
+func (t *Template) Clone() *Template {
+	n := *t
+	n.header = make(http.Header, len(t.header))
+	for k, v := range t.header {
+		n.header[k] = append([]string(nil), v...)
+	}
+	n.params = append([]param(nil), t.params...)
+	n.pathParams = make(map[string]string, len(t.pathParams))
+	for k, v := range t.pathParams {
+		n.pathParams[k] = v
+	}
+	return &n
+}
+
+// Method returns a copy using method.
+func (t *Template) Method(method string) *Template {
+	n := t.Clone()
+	n.method = method
+	return n
+}
+
+// URL returns a copy requesting rawurl.
+func (t *Template) URL(rawurl string) *Template {
+	n := t.Clone()
+	n.rawurl = rawurl
+	return n
+}
+
+// Header returns a copy with the header key set to value.
+func (t *Template) Header(key, value string) *Template {
+	n := t.Clone()
+	n.header.Set(key, value)
+	return n
+}
+
+// Param returns a copy with the param added after the existing ones.
+func (t *Template) Param(key, value string) *Template {
+	n := t.Clone()
+	n.params = append(n.params, param{key, value})
+	return n
+}
+
+// PathParam returns a copy replacing the {key} placeholder of the url with the escaped value.
+func (t *Template) PathParam(key, value string) *Template {
+	n := t.Clone()
+	n.pathParams[key] = value
+	return n
+}
+
+// Body returns a copy with the raw body, it supports string and []byte.
+func (t *Template) Body(data interface{}) *Template {
+	n := t.Clone()
+	switch v := data.(type) {
+	case string:
+		n.body = []byte(v)
+	case []byte:
+		n.body = append([]byte(nil), v...)
+	}
+	return n
+}
+
+// JSONBody returns a copy with obj encoded as JSON body.
+func (t *Template) JSONBody(obj interface{}) (*Template, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return t, err
+	}
+	n := t.Header("Content-Type", "application/json")
+	n.body = data
+	return n, nil
+}
+
+// Setting returns a copy with the settings.
+func (t *Template) Setting(setting HTTPSettings) *Template {
+	n := t.Clone()
+	n.setting = setting
+	n.setting.register()
+	return n
+}
+
+// Build creates a new Context from the template.
+func (t *Template) Build() (*Context, error) {
+	rawurl, err := expandPath(t.rawurl, t.pathParams)
+	if err != nil {
+		return nil, err
+	}
+	b := NewRequest(rawurl, t.method)
+	b.setting = t.setting
+	b.pool = t.pool
+	for k, v := range t.header {
+		b.req.Header[k] = append([]string(nil), v...)
+	}
+	b.params = append(b.params, t.params...)
+	if t.body != nil {
+		b.setBody(t.body)
+	}
+	return b, nil
+}
+
+// Response executes the template and returns the response.
+func (t *Template) Response() (*http.Response, error) {
+	b, err := t.Build()
+	if err != nil {
+		return nil, err
+	}
+	return b.Response()
+}
+
+// Bytes executes the template and returns the body.
+func (t *Template) Bytes() ([]byte, error) {
+	b, err := t.Build()
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes()
+}
+
+// String executes the template and returns the body as string.
+func (t *Template) String() (string, error) {
+	data, err := t.Bytes()
+	return string(data), err
+}
+
+// ToJSON executes the template and decodes the JSON body into v.
+func (t *Template) ToJSON(v interface{}) error {
+	b, err := t.Build()
+	if err != nil {
+		return err
+	}
+	return b.ToJSON(v)
+}
+
+// Decode executes the template and decodes the body according to its Content-Type.
+func (t *Template) Decode(v interface{}) error {
+	b, err := t.Build()
+	if err != nil {
+		return err
+	}
+	return b.Decode(v)
+}
+
+// expandPath replaces the {name} placeholders of rawurl with the escaped values.
+func expandPath(rawurl string, values map[string]string) (string, error) {
+	if !strings.Contains(rawurl, "{") {
+		return rawurl, nil
+	}
+	var buf strings.Builder
+	for {
+		start := strings.IndexByte(rawurl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rawurl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed placeholder in %s", rawurl)
+		}
+		name := rawurl[start+1 : start+end]
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("missing path param %s", name)
+		}
+		buf.WriteString(rawurl[:start])
+		buf.WriteString(url.PathEscape(value))
+		rawurl = rawurl[start+end+1:]
+	}
+	buf.WriteString(rawurl)
+	return buf.String(), nil
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("X-Token")))
+	}))
+	defer ts.Close()
+
+	base := NewTemplate("GET", ts.URL+"/users/{id}/items").Header("X-Token", "t")
+	tpl := base.Param("z", "1").Param("a", "2").Param("z", "3")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := "u " + strconv.Itoa(i)
+			body, err := tpl.PathParam("id", id).String()
+			want := "GET /users/u%20" + strconv.Itoa(i) + "/items?z=1&a=2&z=3 t"
+			if err != nil || body != want {
+				t.Errorf("got %q, %v, want %q", body, err, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := tpl.String(); err == nil {
+		t.Error("expected an error for the missing path param")
+	}
+	if body, _ := base.Method("POST").PathParam("id", "1").String(); body != "POST /users/1/items t" {
+		t.Errorf("derived template got %q", body)
+	}
+	if len(base.params) != 0 || len(base.pathParams) != 0 {
+		t.Error("derived templates changed the base")
+	}
+}
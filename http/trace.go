@@ -0,0 +1,310 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ueffort/goutils/logs"
+)
+
+// RedactedHeaders are the headers hidden from the logs of an Observer.
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Timings is the breakdown of a request, the phases that did not happen are 0,
+// like DNS and Connect on a reused connection.
+type Timings struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration // from sending the request to the first byte of the response
+	Total     time.Duration // until the response headers
+	Reused    bool
+}
+
+func (t Timings) String() string {
+	return fmt.Sprintf("dns=%s connect=%s tls=%s first_byte=%s total=%s reused=%t",
+		t.DNS, t.Connect, t.TLS, t.FirstByte, t.Total, t.Reused)
+}
+
+// RequestInfo describes a request seen by an Observer.
+type RequestInfo struct {
+	Method      string
+	URL         string // the password is redacted
+	Host        string
+	StatusCode  int // 0 when the request failed
+	Err         error
+	Timings     Timings
+	TraceParent string
+}
+
+// ObserverSettings configures an Observer.
+type ObserverSettings struct {
+	// Metrics counts the requests by host and status, nil means no counting.
+	Metrics *Metrics
+	// Log logs every request through logs, at the debug level with the headers.
+	Log bool
+	// Propagate sets a W3C traceparent header on the requests without one,
+	// continuing the trace of the context set by WithTraceParent.
+	Propagate bool
+	// OnRequest is called after every request.
+	OnRequest func(info RequestInfo)
+}
+
+// Observer times the requests with httptrace, counts and logs them.
+//
+// example:
+//
+//	metrics := http.NewMetrics()
+//	observer := http.NewObserver(http.ObserverSettings{Metrics: metrics, Log: true, Propagate: true})
+//	http.Get(url).Intercept(observer.Interceptor()).Bytes()
+type Observer struct {
+	setting ObserverSettings
+}
+
+// NewObserver creates an Observer.
+func NewObserver(setting ObserverSettings) *Observer {
+	return &Observer{setting: setting}
+}
+
+// Interceptor returns the interceptor observing the requests.
+func (o *Observer) Interceptor() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		var traceParent string
+		if o.setting.Propagate {
+			traceParent = req.Header.Get("traceparent")
+			if traceParent == "" {
+				traceParent = newTraceParent(req.Context())
+				req = req.Clone(req.Context())
+				req.Header.Set("traceparent", traceParent)
+			}
+		}
+		rec := &timingRecorder{start: time.Now()}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), rec.trace()))
+		resp, err := next(req)
+
+		info := RequestInfo{
+			Method:      req.Method,
+			URL:         req.URL.Redacted(),
+			Host:        req.URL.Host,
+			Err:         err,
+			Timings:     rec.timings(),
+			TraceParent: traceParent,
+		}
+		if resp != nil {
+			info.StatusCode = resp.StatusCode
+		}
+		if o.setting.Metrics != nil {
+			o.setting.Metrics.add(info)
+		}
+		if o.setting.Log {
+			logRequest(req, resp, info)
+		}
+		if o.setting.OnRequest != nil {
+			o.setting.OnRequest(info)
+		}
+		return resp, err
+	}
+}
+
+func logRequest(req *http.Request, resp *http.Response, info RequestInfo) {
+	if info.Err != nil {
+		logs.Error("http %s %s failed: %s %s", info.Method, info.URL, info.Err, info.Timings)
+		return
+	}
+	logs.Info("http %s %s %d %s", info.Method, info.URL, info.StatusCode, info.Timings)
+	logs.Debug("http %s %s request headers %v response headers %v",
+		info.Method, info.URL, RedactHeader(req.Header), RedactHeader(resp.Header))
+}
+
+// RedactHeader returns a copy of h with the values of RedactedHeaders hidden.
+func RedactHeader(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range RedactedHeaders {
+		if values, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = "[REDACTED]"
+			}
+		}
+	}
+	return redacted
+}
+
+// timingRecorder collects the httptrace events of a request,
+// the connect events may come from several goroutines.
+type timingRecorder struct {
+	start     time.Time
+	dnsStart  time.Time
+	dialStart time.Time
+	tlsStart  time.Time
+	wrote     time.Time
+	t         Timings
+	sync.Mutex
+}
+
+func (r *timingRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.Lock()
+			r.dnsStart = time.Now()
+			r.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.Lock()
+			r.t.DNS = time.Since(r.dnsStart)
+			r.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			r.Lock()
+			if r.dialStart.IsZero() {
+				r.dialStart = time.Now()
+			}
+			r.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			r.Lock()
+			if err == nil && r.t.Connect == 0 {
+				r.t.Connect = time.Since(r.dialStart)
+			}
+			r.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			r.Lock()
+			r.tlsStart = time.Now()
+			r.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.Lock()
+			r.t.TLS = time.Since(r.tlsStart)
+			r.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.Lock()
+			r.t.Reused = info.Reused
+			r.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			r.Lock()
+			r.wrote = time.Now()
+			r.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			r.Lock()
+			if !r.wrote.IsZero() {
+				r.t.FirstByte = time.Since(r.wrote)
+			}
+			r.Unlock()
+		},
+	}
+}
+
+func (r *timingRecorder) timings() Timings {
+	r.Lock()
+	defer r.Unlock()
+	t := r.t
+	t.Total = time.Since(r.start)
+	return t
+}
+
+// Metrics counts the requests by host and status code, it is safe for concurrent use.
+type Metrics struct {
+	counts map[metricKey]*MetricCount
+	sync.Mutex
+}
+
+type metricKey struct {
+	host   string
+	status int
+}
+
+// MetricCount is the number and the total duration of the requests to Host answered with Status,
+// Status is 0 for the failed requests.
+type MetricCount struct {
+	Host   string
+	Status int
+	Count  int64
+	Total  time.Duration
+}
+
+// NewMetrics creates empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{counts: make(map[metricKey]*MetricCount)}
+}
+
+func (m *Metrics) add(info RequestInfo) {
+	key := metricKey{info.Host, info.StatusCode}
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.counts[key]
+	if !ok {
+		c = &MetricCount{Host: info.Host, Status: info.StatusCode}
+		m.counts[key] = c
+	}
+	c.Count++
+	c.Total += info.Timings.Total
+}
+
+// Count returns the number of requests to host answered with status.
+func (m *Metrics) Count(host string, status int) int64 {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.counts[metricKey{host, status}]; ok {
+		return c.Count
+	}
+	return 0
+}
+
+// Snapshot returns the counts sorted by host and status.
+func (m *Metrics) Snapshot() []MetricCount {
+	m.Lock()
+	res := make([]MetricCount, 0, len(m.counts))
+	for _, c := range m.counts {
+		res = append(res, *c)
+	}
+	m.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Host != res[j].Host {
+			return res[i].Host < res[j].Host
+		}
+		return res[i].Status < res[j].Status
+	})
+	return res
+}
+
+// Reset clears the counts.
+func (m *Metrics) Reset() {
+	m.Lock()
+	m.counts = make(map[metricKey]*MetricCount)
+	m.Unlock()
+}
+
+type traceParentKey struct{}
+
+// WithTraceParent returns a context whose requests continue the trace of the
+// W3C traceparent header value, usually the one of an incoming request.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// newTraceParent returns a traceparent with a new span id, in the trace of ctx or a new one.
+func newTraceParent(ctx context.Context) string {
+	var id [24]byte
+	rand.Read(id[:])
+	traceID, flags := hex.EncodeToString(id[:16]), "01"
+	if parent, ok := ctx.Value(traceParentKey{}).(string); ok {
+		// version-traceid-parentid-flags
+		parts := strings.Split(parent, "-")
+		if len(parts) == 4 && len(parts[1]) == 32 && len(parts[3]) == 2 {
+			traceID, flags = parts[1], parts[3]
+		}
+	}
+	return "00-" + traceID + "-" + hex.EncodeToString(id[16:]) + "-" + flags
+}
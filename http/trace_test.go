@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(r.Header.Get("traceparent")))
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var infos []RequestInfo
+	metrics := NewMetrics()
+	observer := NewObserver(ObserverSettings{
+		Metrics:   metrics,
+		Log:       true,
+		Propagate: true,
+		OnRequest: func(info RequestInfo) {
+			mu.Lock()
+			infos = append(infos, info)
+			mu.Unlock()
+		},
+	})
+	client := NewClient(HTTPSettings{Interceptors: []Interceptor{observer.Interceptor()}})
+
+	traceParent := regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+	body, err := client.Get(ts.URL).String()
+	if err != nil || !traceParent.MatchString(body) {
+		t.Errorf("traceparent %q, %v", body, err)
+	}
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	body, _ = client.Get(ts.URL).WithContext(WithTraceParent(context.Background(), parent)).String()
+	if !strings.HasPrefix(body, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || body == parent {
+		t.Errorf("the trace was not continued: %q", body)
+	}
+	client.Get(strings.Replace(ts.URL, "http://", "http://user:secret@", 1) + "/missing").Bytes()
+
+	host := ts.Listener.Addr().String()
+	if metrics.Count(host, 200) != 2 || metrics.Count(host, 404) != 1 || len(metrics.Snapshot()) != 2 {
+		t.Errorf("unexpected metrics %v", metrics.Snapshot())
+	}
+	if len(infos) != 3 {
+		t.Fatalf("got %d infos", len(infos))
+	}
+	if strings.Contains(infos[2].URL, "secret") {
+		t.Errorf("the password was not redacted: %s", infos[2].URL)
+	}
+	if infos[0].Timings.Connect <= 0 || infos[0].Timings.Reused || !infos[1].Timings.Reused {
+		t.Errorf("unexpected timings %s, %s", infos[0].Timings, infos[1].Timings)
+	}
+	if infos[0].Timings.FirstByte <= 0 || infos[0].Timings.Total < infos[0].Timings.FirstByte {
+		t.Errorf("unexpected timings %s", infos[0].Timings)
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Accept", "*/*")
+	redacted := RedactHeader(h)
+	if redacted.Get("Authorization") != "[REDACTED]" || redacted.Get("Accept") != "*/*" {
+		t.Errorf("got %v", redacted)
+	}
+	if h.Get("Authorization") != "Bearer secret" {
+		t.Error("the original header was changed")
+	}
+}
@@ -0,0 +1,38 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ueffort/goutils/uuid"
+)
+
+type uuidRecord struct {
+	Id   uuid.UUID `json:"id" orm:"primary"`
+	Name string    `json:"name"`
+}
+
+func TestSchemaConversion(t *testing.T) {
+	record := &uuidRecord{Id: uuid.NewV7(), Name: "a"}
+	schema, err := NewSchema(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := interface2map(schema, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := data["id"].([]byte)
+	if !ok || string(raw) != record.Id.String() {
+		t.Fatalf("id stored as %#v, want %s", data["id"], record.Id)
+	}
+
+	loaded := &uuidRecord{}
+	field := reflect.ValueOf(loaded).Elem().FieldByName("Id")
+	if err := bytes2Value(nil, schema.columns["id"], &field, raw); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != record.Id {
+		t.Errorf("loaded %s, want %s", loaded.Id, record.Id)
+	}
+}
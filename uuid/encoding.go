@@ -0,0 +1,112 @@
+package uuid
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Nil is the UUID with all bits set to zero.
+var Nil UUID
+
+// String returns the UUID in xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx format.
+func (this UUID) String() string {
+	return this.Hex()
+}
+
+// IsNil reports whether the UUID is Nil.
+func (this UUID) IsNil() bool {
+	return this == Nil
+}
+
+// Equal reports whether both UUIDs are the same.
+func (this UUID) Equal(other UUID) bool {
+	return this == other
+}
+
+// Compare returns -1, 0 or +1 comparing the UUIDs byte by byte,
+// so the v6 and v7 UUIDs compare by creation time, but not the v1 ones.
+func (this UUID) Compare(other UUID) int {
+	return bytes.Compare(this[:], other[:])
+}
+
+// Bytes returns the 16 bytes of the UUID.
+func (this UUID) Bytes() []byte {
+	return this[:]
+}
+
+// FromBytes returns a UUID from its 16 byte binary form.
+func FromBytes(b []byte) (id UUID, err error) {
+	err = id.UnmarshalBinary(b)
+	return
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (this UUID) MarshalText() ([]byte, error) {
+	return this.AppendHex(make([]byte, 0, 36)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, it accepts the formats of FromStr.
+func (this *UUID) UnmarshalText(text []byte) error {
+	id, err := FromStr(string(text))
+	if err != nil {
+		return err
+	}
+	*this = id
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (this UUID) MarshalBinary() ([]byte, error) {
+	return this.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (this *UUID) UnmarshalBinary(data []byte) error {
+	if len(data) != 16 {
+		return fmt.Errorf("Invalid UUID length %d", len(data))
+	}
+	copy(this[:], data)
+	return nil
+}
+
+// Scan implements sql.Scanner. It accepts the text forms of FromStr,
+// the 16 byte binary form and NULL, which scans to Nil.
+func (this *UUID) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*this = Nil
+		return nil
+	case string:
+		if s == "" {
+			*this = Nil
+			return nil
+		}
+		return this.UnmarshalText([]byte(s))
+	case []byte:
+		return this.FromDB(s)
+	}
+	return fmt.Errorf("Unable to scan %T into UUID", src)
+}
+
+// Value implements driver.Valuer, the UUID is stored in its text form.
+func (this UUID) Value() (driver.Value, error) {
+	return this.Hex(), nil
+}
+
+// FromDB implements orm.Conversion, see Scan.
+func (this *UUID) FromDB(data []byte) error {
+	switch len(data) {
+	case 0:
+		*this = Nil
+		return nil
+	case 16:
+		return this.UnmarshalBinary(data)
+	}
+	return this.UnmarshalText(data)
+}
+
+// ToDB implements orm.Conversion, the UUID is stored in its text form.
+func (this UUID) ToDB() ([]byte, error) {
+	return this.MarshalText()
+}
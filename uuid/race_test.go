@@ -0,0 +1,6 @@
+//go:build race
+
+package uuid
+
+// raceEnabled is set when the race detector, which drops sync.Pool items at random, is on.
+const raceEnabled = true
@@ -0,0 +1,252 @@
+package uuid
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrClockRollback is returned when the clock moved back further than SnowflakeSettings.MaxRollback.
+var ErrClockRollback = errors.New("clock moved backwards")
+
+// ErrTimestampOverflow is returned when the milliseconds since the epoch no longer fit in the timestamp bits.
+var ErrTimestampOverflow = errors.New("snowflake timestamp overflows its bits")
+
+// ID is a 64-bit k-sortable id made of a millisecond timestamp, a datacenter id,
+// a worker id and a sequence number, from the most to the least significant bits.
+type ID int64
+
+// SnowflakeSettings configures the bit layout and identity of a Snowflake generator.
+type SnowflakeSettings struct {
+	Epoch          time.Time
+	DatacenterBits uint
+	WorkerBits     uint
+	SequenceBits   uint
+	DatacenterID   int64
+	WorkerID       int64
+	// MaxRollback is how far the clock may move back before Next fails,
+	// smaller rollbacks are waited out.
+	MaxRollback time.Duration
+}
+
+// DefaultSnowflakeSettings uses the Twitter layout: 41 bits of milliseconds since
+// 2020-01-01, 5 datacenter bits, 5 worker bits and 12 sequence bits.
+var DefaultSnowflakeSettings = SnowflakeSettings{
+	Epoch:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	DatacenterBits: 5,
+	WorkerBits:     5,
+	SequenceBits:   12,
+	MaxRollback:    time.Second,
+}
+
+// SnowflakeParts is a decoded ID.
+type SnowflakeParts struct {
+	Time       time.Time
+	Datacenter int64
+	Worker     int64
+	Sequence   int64
+}
+
+// Snowflake generates IDs, it is safe for concurrent use.
+type Snowflake struct {
+	setting SnowflakeSettings
+	epoch   int64
+	last    int64
+	seq     int64
+	now     func() time.Time
+	sync.Mutex
+}
+
+// NewSnowflake creates a generator, the ids must fit in their bits and at least
+// 32 bits must be left for the timestamp, and the milliseconds since the epoch
+// must fit in them. A zero Epoch means the default one, an Epoch in the future is an error.
+func NewSnowflake(setting SnowflakeSettings) (*Snowflake, error) {
+	if setting.Epoch.IsZero() {
+		setting.Epoch = DefaultSnowflakeSettings.Epoch
+	}
+	if setting.Epoch.After(time.Now()) {
+		return nil, fmt.Errorf("snowflake epoch %s is in the future", setting.Epoch)
+	}
+	if setting.DatacenterBits+setting.WorkerBits+setting.SequenceBits > 31 {
+		return nil, errors.New("snowflake leaves less than 32 timestamp bits")
+	}
+	if setting.DatacenterID < 0 || setting.DatacenterID >= 1<<setting.DatacenterBits {
+		return nil, fmt.Errorf("datacenter id %d does not fit in %d bits", setting.DatacenterID, setting.DatacenterBits)
+	}
+	if setting.WorkerID < 0 || setting.WorkerID >= 1<<setting.WorkerBits {
+		return nil, fmt.Errorf("worker id %d does not fit in %d bits", setting.WorkerID, setting.WorkerBits)
+	}
+	s := &Snowflake{
+		setting: setting,
+		epoch:   setting.Epoch.UnixNano() / 1e6,
+		last:    -1,
+		now:     time.Now,
+	}
+	if s.overflows(s.millis()) {
+		return nil, ErrTimestampOverflow
+	}
+	return s, nil
+}
+
+// overflows reports whether ms does not fit in the timestamp bits.
+func (s *Snowflake) overflows(ms int64) bool {
+	st := s.setting
+	return ms >= 1<<(63-st.DatacenterBits-st.WorkerBits-st.SequenceBits)
+}
+
+// Next returns a new ID, greater than every ID returned before by this generator.
+// When the sequence of the current millisecond is exhausted it waits for the next one.
+// It fails with ErrTimestampOverflow once the timestamp bits are used up.
+func (s *Snowflake) Next() (ID, error) {
+	s.Lock()
+	defer s.Unlock()
+	ms := s.millis()
+	if ms < s.last {
+		if time.Duration(s.last-ms)*time.Millisecond > s.setting.MaxRollback {
+			return 0, ErrClockRollback
+		}
+		ms = s.waitAfter(s.last - 1)
+	}
+	if ms == s.last {
+		s.seq = (s.seq + 1) & (1<<s.setting.SequenceBits - 1)
+		if s.seq == 0 {
+			ms = s.waitAfter(s.last)
+		}
+	} else {
+		s.seq = 0
+	}
+	if s.overflows(ms) {
+		return 0, ErrTimestampOverflow
+	}
+	s.last = ms
+	st := s.setting
+	return ID(ms<<(st.DatacenterBits+st.WorkerBits+st.SequenceBits) |
+		st.DatacenterID<<(st.WorkerBits+st.SequenceBits) |
+		st.WorkerID<<st.SequenceBits |
+		s.seq), nil
+}
+
+// MustNext behaves like Next except that it panics instead of returning an error.
+func (s *Snowflake) MustNext() ID {
+	id, err := s.Next()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Decode splits id into its parts using the layout of the generator.
+func (s *Snowflake) Decode(id ID) SnowflakeParts {
+	st := s.setting
+	v := int64(id)
+	ms := v>>(st.DatacenterBits+st.WorkerBits+st.SequenceBits) + s.epoch
+	return SnowflakeParts{
+		Time:       time.Unix(ms/1e3, ms%1e3*1e6),
+		Datacenter: v >> (st.WorkerBits + st.SequenceBits) & (1<<st.DatacenterBits - 1),
+		Worker:     v >> st.SequenceBits & (1<<st.WorkerBits - 1),
+		Sequence:   v & (1<<st.SequenceBits - 1),
+	}
+}
+
+// millis returns the milliseconds since the epoch.
+func (s *Snowflake) millis() int64 {
+	return s.now().UnixNano()/1e6 - s.epoch
+}
+
+// waitAfter blocks until the clock passes ms.
+func (s *Snowflake) waitAfter(ms int64) int64 {
+	now := s.millis()
+	for now <= ms {
+		time.Sleep(time.Duration(ms-now+1) * time.Millisecond / 2)
+		now = s.millis()
+	}
+	return now
+}
+
+const (
+	base32Alphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base32Len      = 13
+	base62Len      = 11
+)
+
+// Int64 returns the id as an int64.
+func (id ID) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the id in decimal.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// Base32 returns the id in 13 characters of Crockford's base32.
+// The width is fixed so the strings sort like the ids.
+func (id ID) Base32() string {
+	var b [base32Len]byte
+	v := uint64(id)
+	for i := base32Len - 1; i >= 0; i-- {
+		b[i] = base32Alphabet[v&31]
+		v >>= 5
+	}
+	return string(b[:])
+}
+
+// Base62 returns the id in 11 characters of base62.
+// The width is fixed so the strings sort like the ids.
+func (id ID) Base62() string {
+	var b [base62Len]byte
+	v := uint64(id)
+	for i := base62Len - 1; i >= 0; i-- {
+		b[i] = base62Alphabet[v%62]
+		v /= 62
+	}
+	return string(b[:])
+}
+
+// ParseBase32 parses an id returned by Base32, it is case insensitive.
+func ParseBase32(s string) (ID, error) {
+	if len(s) != base32Len {
+		return 0, errors.New("Invalid base32 id length")
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		d := indexByte(base32Alphabet, c)
+		if d < 0 || (i == 0 && d > 7) {
+			return 0, errors.New("Invalid base32 id")
+		}
+		v = v<<5 | uint64(d)
+	}
+	return ID(v), nil
+}
+
+// ParseBase62 parses an id returned by Base62.
+func ParseBase62(s string) (ID, error) {
+	if len(s) != base62Len {
+		return 0, errors.New("Invalid base62 id length")
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		d := indexByte(base62Alphabet, s[i])
+		if d < 0 || v > (1<<63-1-uint64(d))/62 {
+			return 0, errors.New("Invalid base62 id")
+		}
+		v = v*62 + uint64(d)
+	}
+	return ID(v), nil
+}
+
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
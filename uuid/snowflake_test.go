@@ -0,0 +1,156 @@
+package uuid
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnowflakeConcurrent(t *testing.T) {
+	const (
+		workers = 8
+		count   = 20000
+	)
+	s, err := NewSnowflake(DefaultSnowflakeSettings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make([][]ID, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ids := make([]ID, count)
+			for i := range ids {
+				ids[i] = s.MustNext()
+			}
+			results[w] = ids
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make(map[ID]bool, workers*count)
+	for _, ids := range results {
+		for i, id := range ids {
+			if i > 0 && id <= ids[i-1] {
+				t.Fatalf("%d not after %d", id, ids[i-1])
+			}
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestSnowflakeDecode(t *testing.T) {
+	setting := DefaultSnowflakeSettings
+	setting.DatacenterID = 3
+	setting.WorkerID = 17
+	s, err := NewSnowflake(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := s.MustNext()
+	parts := s.Decode(id)
+	if parts.Datacenter != 3 || parts.Worker != 17 || parts.Sequence != 0 {
+		t.Errorf("decoded %+v", parts)
+	}
+	if d := time.Since(parts.Time); d < 0 || d > time.Second {
+		t.Errorf("decoded time %s", parts.Time)
+	}
+
+	setting.WorkerID = 32
+	if _, err := NewSnowflake(setting); err == nil {
+		t.Error("expected an error for a worker id out of range")
+	}
+
+	setting = DefaultSnowflakeSettings
+	setting.Epoch = time.Now().Add(time.Hour)
+	if _, err := NewSnowflake(setting); err == nil {
+		t.Error("expected an error for an epoch in the future")
+	}
+	setting.Epoch = time.Time{}
+	s, err = NewSnowflake(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := s.MustNext(); id <= 0 || time.Since(s.Decode(id).Time) > time.Second {
+		t.Errorf("zero epoch got id %d at %s", id, s.Decode(id).Time)
+	}
+}
+
+func TestSnowflakeOverflow(t *testing.T) {
+	// 32 bits of milliseconds since 2020 overflowed in 2020
+	setting := DefaultSnowflakeSettings
+	setting.DatacenterBits, setting.WorkerBits, setting.SequenceBits = 8, 8, 15
+	if _, err := NewSnowflake(setting); err != ErrTimestampOverflow {
+		t.Errorf("expected ErrTimestampOverflow, got %v", err)
+	}
+
+	setting.Epoch = time.Now().Add(-time.Hour)
+	s, err := NewSnowflake(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.Next(); err != nil || id <= 0 {
+		t.Errorf("got %d, %v", id, err)
+	}
+	s.now = func() time.Time { return setting.Epoch.Add(time.Duration(1<<32) * time.Millisecond) }
+	if _, err := s.Next(); err != ErrTimestampOverflow {
+		t.Errorf("expected ErrTimestampOverflow, got %v", err)
+	}
+}
+
+func TestSnowflakeClock(t *testing.T) {
+	setting := DefaultSnowflakeSettings
+	setting.SequenceBits = 1
+	setting.MaxRollback = 5 * time.Millisecond
+	s, _ := NewSnowflake(setting)
+	base := time.Now()
+	var mu sync.Mutex
+	clock := base
+	s.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		// every read moves the clock a little so waits terminate
+		clock = clock.Add(100 * time.Microsecond)
+		return clock
+	}
+	a, b, c := s.MustNext(), s.MustNext(), s.MustNext()
+	if !(a < b && b < c) || s.Decode(c).Time.Equal(s.Decode(a).Time) {
+		t.Errorf("sequence exhaustion should move to the next millisecond: %+v %+v", s.Decode(a), s.Decode(c))
+	}
+
+	mu.Lock()
+	clock = clock.Add(-3 * time.Millisecond)
+	mu.Unlock()
+	if d, err := s.Next(); err != nil || d <= c {
+		t.Errorf("small rollback should be waited out, got %d, %v", d, err)
+	}
+
+	mu.Lock()
+	clock = clock.Add(-time.Second)
+	mu.Unlock()
+	if _, err := s.Next(); err != ErrClockRollback {
+		t.Errorf("expected ErrClockRollback, got %v", err)
+	}
+}
+
+func TestIDEncoding(t *testing.T) {
+	for _, id := range []ID{0, 1, 42, 1<<63 - 1, 1234567890123456789} {
+		if got, err := ParseBase32(id.Base32()); err != nil || got != id {
+			t.Errorf("base32 %d -> %s -> %d, %v", id, id.Base32(), got, err)
+		}
+		if got, err := ParseBase62(id.Base62()); err != nil || got != id {
+			t.Errorf("base62 %d -> %s -> %d, %v", id, id.Base62(), got, err)
+		}
+	}
+	if ID(41).Base62() >= ID(42).Base62() || ID(1<<40).Base32() >= ID(1<<41).Base32() {
+		t.Error("encodings should sort like the ids")
+	}
+	if _, err := ParseBase62("zzzzzzzzzzz"); err == nil {
+		t.Error("expected an overflow error")
+	}
+}
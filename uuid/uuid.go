@@ -2,33 +2,56 @@ package uuid
 
 import (
 	crand "crypto/rand"
-	"encoding/hex"
 	"errors"
-	"fmt"
 	mrand "math/rand"
-	"regexp"
-	"strings"
+	"sync"
 	"time"
 )
 
 // seeded indicates if math/rand has been seeded
 var seeded bool = false
 
-// uuidRegex matches the UUID string
-var uuidRegex *regexp.Regexp = regexp.MustCompile(`^\{?([a-fA-F0-9]{8})-?([a-fA-F0-9]{4})-?([a-fA-F0-9]{4})-?([a-fA-F0-9]{4})-?([a-fA-F0-9]{12})\}?$`)
+var (
+	errEmpty  = errors.New("Empty string")
+	errFormat = errors.New("Invalid string format")
+)
+
+// hexDigits is used by AppendHex, fromHex decodes the hex digits and marks other bytes with 0xff.
+const hexDigits = "0123456789abcdef"
+
+var fromHex = func() (t [256]byte) {
+	for i := range t {
+		t[i] = 0xff
+	}
+	for i := 0; i < 10; i++ {
+		t['0'+i] = byte(i)
+	}
+	for i := 0; i < 6; i++ {
+		t['a'+i] = byte(10 + i)
+		t['A'+i] = byte(10 + i)
+	}
+	return
+}()
 
 // UUID type.
 type UUID [16]byte
 
 // Hex returns a hex string representation of the UUID in xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx format.
 func (this UUID) Hex() string {
-	x := [16]byte(this)
-	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%02x%02x%02x%02x%02x%02x",
-		x[0], x[1], x[2], x[3], x[4],
-		x[5], x[6],
-		x[7], x[8],
-		x[9], x[10], x[11], x[12], x[13], x[14], x[15])
+	var buf [36]byte
+	return string(this.AppendHex(buf[:0]))
+}
 
+// AppendHex appends the xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx format of the UUID to dst,
+// it does not allocate if dst has room for 36 more bytes.
+func (this UUID) AppendHex(dst []byte) []byte {
+	for i, b := range this {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			dst = append(dst, '-')
+		}
+		dst = append(dst, hexDigits[b>>4], hexDigits[b&0x0f])
+	}
+	return dst
 }
 
 // Rand generates a new version 4 UUID.
@@ -52,20 +75,35 @@ func Rand() UUID {
 // If the string is not in one of these formats, it'll return an error.
 func FromStr(s string) (id UUID, err error) {
 	if s == "" {
-		err = errors.New("Empty string")
+		err = errEmpty
 		return
 	}
-
-	parts := uuidRegex.FindStringSubmatch(s)
-	if parts == nil {
-		err = errors.New("Invalid string format")
-		return
+	if s[0] == '{' {
+		s = s[1:]
+	}
+	if len(s) > 0 && s[len(s)-1] == '}' {
+		s = s[:len(s)-1]
 	}
 
-	var array [16]byte
-	slice, _ := hex.DecodeString(strings.Join(parts[1:], ""))
-	copy(array[:], slice)
-	id = array
+	j := 0
+	for i := 0; i < 16; i++ {
+		// the groups may be separated by dashes
+		if (i == 4 || i == 6 || i == 8 || i == 10) && j < len(s) && s[j] == '-' {
+			j++
+		}
+		if j+1 >= len(s) {
+			return UUID{}, errFormat
+		}
+		hi, lo := fromHex[s[j]], fromHex[s[j+1]]
+		if hi == 0xff || lo == 0xff {
+			return UUID{}, errFormat
+		}
+		id[i] = hi<<4 | lo
+		j += 2
+	}
+	if j != len(s) {
+		return UUID{}, errFormat
+	}
 	return
 }
 
@@ -79,8 +117,56 @@ func MustFromStr(s string) UUID {
 	return id
 }
 
-// randBytes uses crypto random to get random numbers. If fails then it uses math random.
+// Must returns id, it panics if err is not nil.
+// It simplifies the initialization of variables, e.g. uuid.Must(uuid.FromStr(s)).
+func Must(id UUID, err error) UUID {
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Fill fills ids with new version 4 UUIDs and returns it.
+func Fill(ids []UUID) []UUID {
+	for i := range ids {
+		ids[i] = Rand()
+	}
+	return ids
+}
+
+// randBufferSize is the number of random bytes fetched from crypto random at once.
+const randBufferSize = 4096
+
+type randBuffer struct {
+	buf [randBufferSize]byte
+	pos int
+}
+
+// randPool holds buffers of crypto random bytes, so generating a UUID
+// is a copy instead of a system call.
+var randPool = sync.Pool{
+	New: func() interface{} {
+		return &randBuffer{pos: randBufferSize}
+	},
+}
+
+// randBytes fills x from a pooled buffer of crypto random bytes.
 func randBytes(x []byte) {
+	if len(x) > randBufferSize {
+		readRand(x)
+		return
+	}
+	b := randPool.Get().(*randBuffer)
+	if b.pos+len(x) > randBufferSize {
+		readRand(b.buf[:])
+		b.pos = 0
+	}
+	b.pos += copy(x, b.buf[b.pos:])
+	randPool.Put(b)
+}
+
+// readRand uses crypto random to get random numbers. If fails then it uses math random.
+func readRand(x []byte) {
 
 	length := len(x)
 	n, err := crand.Read(x)
@@ -88,6 +174,7 @@ func randBytes(x []byte) {
 	if n != length || err != nil {
 		if !seeded {
 			mrand.Seed(time.Now().UnixNano())
+			seeded = true
 		}
 
 		for length > 0 {
@@ -0,0 +1,219 @@
+package uuid
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNameBased(t *testing.T) {
+	if id := NewV3(NamespaceDNS, "python.org"); id.Hex() != "6fa459ea-ee8a-3ca4-894e-db77e160355e" {
+		t.Errorf("NewV3 = %s", id.Hex())
+	}
+	if id := NewV5(NamespaceDNS, "python.org"); id.Hex() != "886313e1-3b8a-5372-9b90-0c9aee199e5d" {
+		t.Errorf("NewV5 = %s", id.Hex())
+	}
+}
+
+func TestVersions(t *testing.T) {
+	cases := map[int]UUID{
+		1: NewV1(),
+		3: NewV3(NamespaceURL, "x"),
+		4: Rand(),
+		5: NewV5(NamespaceURL, "x"),
+		6: NewV6(),
+		7: NewV7(),
+	}
+	for v, id := range cases {
+		if id.Version() != v {
+			t.Errorf("%s: version %d, want %d", id.Hex(), id.Version(), v)
+		}
+		if id.Variant() != VariantRFC4122 {
+			t.Errorf("%s: variant %d", id.Hex(), id.Variant())
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	now := time.Now()
+	for _, id := range []UUID{NewV1(), NewV6(), NewV7()} {
+		ts, err := id.Time()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := ts.Sub(now); d < -time.Second || d > time.Second {
+			t.Errorf("%s: time %s, want about %s", id.Hex(), ts, now)
+		}
+	}
+	if _, err := Rand().Time(); err == nil {
+		t.Error("expected an error for version 4")
+	}
+}
+
+func TestTimeOrdered(t *testing.T) {
+	for _, gen := range []func() UUID{NewV6, NewV7} {
+		prev := gen()
+		for i := 0; i < 10000; i++ {
+			id := gen()
+			if bytes.Compare(prev[:], id[:]) >= 0 {
+				t.Fatalf("%s not after %s", id.Hex(), prev.Hex())
+			}
+			prev = id
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	id := Rand()
+	type wrapper struct {
+		Id UUID `json:"id"`
+	}
+	b, err := json.Marshal(wrapper{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"`+id.String()+`"}` {
+		t.Errorf("json = %s", b)
+	}
+	var w wrapper
+	if err := json.Unmarshal(b, &w); err != nil || w.Id != id {
+		t.Errorf("json round trip = %s, %v", w.Id, err)
+	}
+
+	var scanned UUID
+	for _, src := range []interface{}{id.String(), []byte(id.String()), id.Bytes()} {
+		if err := scanned.Scan(src); err != nil || scanned != id {
+			t.Errorf("Scan(%#v) = %s, %v", src, scanned, err)
+		}
+	}
+	if err := scanned.Scan(nil); err != nil || !scanned.IsNil() {
+		t.Errorf("Scan(nil) = %s, %v", scanned, err)
+	}
+	if v, _ := id.Value(); v != id.String() {
+		t.Errorf("Value = %v", v)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("expected an error scanning an int")
+	}
+
+	bin, _ := id.MarshalBinary()
+	if other, err := FromBytes(bin); err != nil || !other.Equal(id) || other.Compare(id) != 0 {
+		t.Errorf("binary round trip = %s, %v", other, err)
+	}
+	if Nil.Compare(id) != -1 && !id.IsNil() {
+		t.Errorf("Nil should sort first")
+	}
+}
+
+func TestFromStr(t *testing.T) {
+	want := MustFromStr("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	for _, s := range []string{
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"6BA7B8109DAD11D180B400C04FD430C8",
+		"{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+		"{6ba7b8109dad11d180b400c04fd430c8}",
+		"6ba7b810-9dad11d1-80b4-00c04fd430c8",
+	} {
+		if id, err := FromStr(s); err != nil || id != want {
+			t.Errorf("FromStr(%q) = %s, %v", s, id, err)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c8a",
+		"6ba7b810--9dad-11d1-80b4-00c04fd430c8",
+		"6ba7b81-09dad-11d1-80b4-00c04fd430c8",
+		"6ba7b810-9dad-11d1-80b4-00c04fd430cg",
+	} {
+		if _, err := FromStr(s); err == nil {
+			t.Errorf("FromStr(%q) should fail", s)
+		}
+	}
+	if want.Hex() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Hex = %s", want.Hex())
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	id := Rand()
+	s := id.Hex()
+	buf := make([]byte, 0, 36)
+	cases := map[string]func(){
+		"Rand":      func() { Rand() },
+		"FromStr":   func() { FromStr(s) },
+		"AppendHex": func() { id.AppendHex(buf) },
+	}
+	if raceEnabled {
+		// Rand takes its buffers from a sync.Pool
+		delete(cases, "Rand")
+	}
+	for name, f := range cases {
+		if n := testing.AllocsPerRun(100, f); n != 0 {
+			t.Errorf("%s allocates %.0f times", name, n)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { _ = id.Hex() }); n > 1 {
+		t.Errorf("Hex allocates %.0f times", n)
+	}
+}
+
+func TestFill(t *testing.T) {
+	ids := Fill(make([]UUID, 100))
+	seen := make(map[UUID]bool)
+	for _, id := range ids {
+		if id.Version() != 4 || seen[id] {
+			t.Fatalf("bad or duplicate id %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func BenchmarkRand(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Rand()
+	}
+}
+
+func BenchmarkRandParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Rand()
+		}
+	})
+}
+
+func BenchmarkNewV7(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewV7()
+	}
+}
+
+func BenchmarkFromStr(b *testing.B) {
+	s := Rand().Hex()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FromStr(s)
+	}
+}
+
+func BenchmarkHex(b *testing.B) {
+	id := Rand()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = id.Hex()
+	}
+}
+
+func BenchmarkAppendHex(b *testing.B) {
+	id := Rand()
+	buf := make([]byte, 0, 36)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		id.AppendHex(buf)
+	}
+}
@@ -0,0 +1,200 @@
+package uuid
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"net"
+	"sync"
+	"time"
+)
+
+// Variant is the layout of a UUID as encoded in its variant bits.
+type Variant byte
+
+const (
+	VariantNCS Variant = iota
+	VariantRFC4122
+	VariantMicrosoft
+	VariantFuture
+)
+
+// Predefined namespaces for name-based UUIDs (RFC 4122, appendix C).
+var (
+	NamespaceDNS  = MustFromStr("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	NamespaceURL  = MustFromStr("6ba7b811-9dad-11d1-80b4-00c04fd430c8")
+	NamespaceOID  = MustFromStr("6ba7b812-9dad-11d1-80b4-00c04fd430c8")
+	NamespaceX500 = MustFromStr("6ba7b814-9dad-11d1-80b4-00c04fd430c8")
+)
+
+// gregorianOffset is the number of 100ns intervals between 1582-10-15 and 1970-01-01.
+const gregorianOffset = 122192928000000000
+
+// clock keeps the state shared by the time-based versions.
+var clock struct {
+	sync.Mutex
+	last     uint64 // last gregorian timestamp used by v1/v6
+	seq      uint16 // clock sequence of v1/v6
+	node     [6]byte
+	lastMs   int64  // last unix millisecond used by v7
+	msSeq    uint16 // counter of v7 within lastMs
+	prepared bool
+}
+
+// Version returns the version number of the UUID.
+func (this UUID) Version() int {
+	return int(this[6] >> 4)
+}
+
+// Variant returns the variant of the UUID.
+func (this UUID) Variant() Variant {
+	switch {
+	case this[8]&0x80 == 0:
+		return VariantNCS
+	case this[8]&0xc0 == 0x80:
+		return VariantRFC4122
+	case this[8]&0xe0 == 0xc0:
+		return VariantMicrosoft
+	}
+	return VariantFuture
+}
+
+// Time returns the timestamp of a version 1, 6 or 7 UUID.
+func (this UUID) Time() (time.Time, error) {
+	switch this.Version() {
+	case 1:
+		ts := uint64(binary.BigEndian.Uint16(this[6:8])&0x0fff)<<48 |
+			uint64(binary.BigEndian.Uint16(this[4:6]))<<32 |
+			uint64(binary.BigEndian.Uint32(this[0:4]))
+		return gregorianTime(ts), nil
+	case 6:
+		ts := uint64(binary.BigEndian.Uint32(this[0:4]))<<28 |
+			uint64(binary.BigEndian.Uint16(this[4:6]))<<12 |
+			uint64(binary.BigEndian.Uint16(this[6:8])&0x0fff)
+		return gregorianTime(ts), nil
+	case 7:
+		ms := int64(binary.BigEndian.Uint64(this[0:8]) >> 16)
+		return time.Unix(ms/1e3, ms%1e3*1e6), nil
+	}
+	return time.Time{}, errors.New("UUID version has no timestamp")
+}
+
+func gregorianTime(ts uint64) time.Time {
+	ns := int64(ts-gregorianOffset) * 100
+	return time.Unix(ns/1e9, ns%1e9)
+}
+
+// NewV1 generates a new version 1 UUID from the current time and the MAC
+// address of the host, or a random node id if there is none.
+func NewV1() UUID {
+	ts, seq, node := gregorianNow()
+	var x UUID
+	binary.BigEndian.PutUint32(x[0:4], uint32(ts))
+	binary.BigEndian.PutUint16(x[4:6], uint16(ts>>32))
+	binary.BigEndian.PutUint16(x[6:8], uint16(ts>>48)&0x0fff|0x1000)
+	binary.BigEndian.PutUint16(x[8:10], seq&0x3fff|0x8000)
+	copy(x[10:], node[:])
+	return x
+}
+
+// NewV3 generates a new version 3 UUID from the MD5 hash of namespace and name.
+func NewV3(namespace UUID, name string) UUID {
+	return fromHash(md5.New(), namespace, name, 3)
+}
+
+// NewV4 generates a new version 4 UUID, it is the same as Rand.
+func NewV4() UUID {
+	return Rand()
+}
+
+// NewV5 generates a new version 5 UUID from the SHA-1 hash of namespace and name.
+func NewV5(namespace UUID, name string) UUID {
+	return fromHash(sha1.New(), namespace, name, 5)
+}
+
+// NewV6 generates a new version 6 UUID, a version 1 UUID with the timestamp
+// reordered so that UUIDs sort by creation time.
+func NewV6() UUID {
+	ts, seq, node := gregorianNow()
+	var x UUID
+	binary.BigEndian.PutUint32(x[0:4], uint32(ts>>28))
+	binary.BigEndian.PutUint16(x[4:6], uint16(ts>>12))
+	binary.BigEndian.PutUint16(x[6:8], uint16(ts)&0x0fff|0x6000)
+	binary.BigEndian.PutUint16(x[8:10], seq&0x3fff|0x8000)
+	copy(x[10:], node[:])
+	return x
+}
+
+// NewV7 generates a new version 7 UUID from the unix time in milliseconds
+// followed by random bits. UUIDs generated by the process are strictly increasing,
+// which keeps database indexes compact.
+func NewV7() UUID {
+	var x UUID
+	randBytes(x[8:])
+
+	clock.Lock()
+	ms := time.Now().UnixNano() / 1e6
+	if ms > clock.lastMs {
+		clock.lastMs = ms
+		// start the counter in the lower half so a burst has room to grow
+		clock.msSeq = uint16(x[8])<<3&0x07ff | uint16(x[9])>>5
+	} else {
+		clock.msSeq++
+		if clock.msSeq > 0x0fff {
+			clock.lastMs++
+			clock.msSeq = 0
+		}
+	}
+	ms, seq := clock.lastMs, clock.msSeq
+	clock.Unlock()
+
+	binary.BigEndian.PutUint64(x[0:8], uint64(ms)<<16|uint64(seq)|0x7000)
+	x[8] = (x[8] & 0x3f) | 0x80
+	return x
+}
+
+func fromHash(h hash.Hash, namespace UUID, name string, version byte) UUID {
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	var x UUID
+	copy(x[:], h.Sum(nil))
+	x[6] = (x[6] & 0x0f) | version<<4
+	x[8] = (x[8] & 0x3f) | 0x80
+	return x
+}
+
+// gregorianNow returns a strictly increasing timestamp in 100ns intervals since
+// 1582-10-15 with the clock sequence and node id.
+func gregorianNow() (uint64, uint16, [6]byte) {
+	clock.Lock()
+	defer clock.Unlock()
+	if !clock.prepared {
+		prepareClock()
+	}
+	ts := uint64(time.Now().UnixNano()/100) + gregorianOffset
+	if ts <= clock.last {
+		ts = clock.last + 1
+	}
+	clock.last = ts
+	return ts, clock.seq, clock.node
+}
+
+// need clock.Lock() before calling
+func prepareClock() {
+	var b [2]byte
+	randBytes(b[:])
+	clock.seq = binary.BigEndian.Uint16(b[:])
+	clock.prepared = true
+	interfaces, _ := net.Interfaces()
+	for _, i := range interfaces {
+		if len(i.HardwareAddr) == 6 {
+			copy(clock.node[:], i.HardwareAddr)
+			return
+		}
+	}
+	randBytes(clock.node[:])
+	// set the multicast bit so a random node can't collide with a real MAC address
+	clock.node[0] |= 0x01
+}